@@ -0,0 +1,203 @@
+package pseudoterm
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+)
+
+// ErrBufferFull is returned when the output buffer overflows using OverflowFail
+var ErrBufferFull = errors.New("Output buffer is full")
+
+var errSkipBuffer = errors.New("Can't watch a terminal using SkipBuffer")
+
+// OverflowPolicy tells what happens when the output buffer of a Terminal is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock stops reading the output until lines are handled,
+	// so the program blocks when writing to the terminal
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest drops the oldest output not handled yet
+	OverflowDropOldest
+
+	// OverflowFail stops the story with ErrBufferFull
+	OverflowFail
+)
+
+// outputBuffer is a ring buffer of the output not handled yet.
+// It grows as needed when its size is zero.
+type outputBuffer struct {
+	size    int
+	policy  OverflowPolicy
+	mu      sync.Mutex
+	space   *sync.Cond
+	data    []byte
+	start   int
+	length  int
+	dropped int64
+	err     error
+	closed  bool
+}
+
+func newOutputBuffer(size int, policy OverflowPolicy) *outputBuffer {
+	var b = &outputBuffer{
+		size:   size,
+		policy: policy,
+		data:   make([]byte, size),
+	}
+
+	b.space = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *outputBuffer) Write(p []byte) (n int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(p) != 0 {
+		// nobody handles the output anymore
+		if b.closed {
+			return n + len(p), nil
+		}
+
+		var free = len(b.data) - b.length
+
+		switch {
+		case free != 0:
+		case b.size == 0:
+			b.grow(len(p))
+			continue
+		case b.policy == OverflowDropOldest:
+			var drop = len(p)
+
+			if drop > b.length {
+				drop = b.length
+			}
+
+			b.discard(drop)
+			b.dropped += int64(drop)
+			continue
+		case b.policy == OverflowFail:
+			b.err = ErrBufferFull
+			return n, ErrBufferFull
+		default:
+			b.space.Wait()
+			continue
+		}
+
+		if free > len(p) {
+			free = len(p)
+		}
+
+		b.put(p[:free])
+		p = p[free:]
+		n += free
+	}
+
+	return n, nil
+}
+
+// put bytes that fit on the free space
+func (b *outputBuffer) put(p []byte) {
+	var end = (b.start + b.length) % len(b.data)
+	var c = copy(b.data[end:], p)
+	copy(b.data, p[c:])
+	b.length += len(p)
+}
+
+func (b *outputBuffer) grow(n int) {
+	var size = 2*len(b.data) + n
+	var data = make([]byte, size)
+	b.copyTo(data, b.length)
+	b.data = data
+	b.start = 0
+}
+
+// copyTo copies the first n buffered bytes in order
+func (b *outputBuffer) copyTo(p []byte, n int) {
+	var c = copy(p[:n], b.data[b.start:])
+	copy(p[c:n], b.data)
+}
+
+// index of the first delim on the buffered bytes, or -1
+func (b *outputBuffer) index(delim byte) int {
+	var first = b.data[b.start:]
+
+	if len(first) > b.length {
+		first = first[:b.length]
+	}
+
+	if i := bytes.IndexByte(first, delim); i != -1 {
+		return i
+	}
+
+	if i := bytes.IndexByte(b.data[:b.length-len(first)], delim); i != -1 {
+		return len(first) + i
+	}
+
+	return -1
+}
+
+func (b *outputBuffer) discard(n int) {
+	b.start = (b.start + n) % len(b.data)
+	b.length -= n
+
+	if b.length == 0 {
+		b.start = 0
+	}
+
+	b.space.Broadcast()
+}
+
+// ReadString reads until the first delim or the end of the buffered output,
+// like bytes.Buffer ReadString, but without an io.EOF error
+func (b *outputBuffer) ReadString(delim byte) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.length == 0 {
+		return ""
+	}
+
+	var n = b.length
+
+	if i := b.index(delim); i != -1 {
+		n = i + 1
+	}
+
+	var p = make([]byte, n)
+	b.copyTo(p, n)
+	b.discard(n)
+	return string(p)
+}
+
+// Len of the buffered output
+func (b *outputBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.length
+}
+
+// Dropped bytes of output
+func (b *outputBuffer) Dropped() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// Err tells if the buffer overflowed
+func (b *outputBuffer) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// Close the buffer, discarding output written from now on
+func (b *outputBuffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.space.Broadcast()
+}
@@ -0,0 +1,148 @@
+package pseudoterm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputBuffer(t *testing.T) {
+	var b = newOutputBuffer(0, OverflowFail)
+
+	for c := 0; c < 100; c++ {
+		fmt.Fprintf(b, "line %d\n", c)
+	}
+
+	for c := 0; c < 100; c++ {
+		if got, want := b.ReadString('\n'), fmt.Sprintf("line %d\n", c); got != want {
+			t.Fatalf("Expected line %q, got %q instead", want, got)
+		}
+	}
+
+	_, _ = io.WriteString(b, "Your name: ")
+
+	if got := b.ReadString('\n'); got != "Your name: " {
+		t.Errorf("Expected partial line, got %q instead", got)
+	}
+
+	if b.Len() != 0 || b.ReadString('\n') != "" {
+		t.Errorf("Expected buffer to be empty")
+	}
+}
+
+func TestOutputBufferDropOldest(t *testing.T) {
+	var b = newOutputBuffer(8, OverflowDropOldest)
+	_, _ = io.WriteString(b, "abc\ndef\n")
+	b.ReadString('\n')
+	_, _ = io.WriteString(b, "ghi\njkl\n")
+
+	// "def\n" is dropped to make room for "jkl\n"
+	if got := b.ReadString('\n'); got != "ghi\n" {
+		t.Errorf("Expected line wrapping around the ring, got %q instead", got)
+	}
+
+	_, _ = io.WriteString(b, "mnopqrstuvwxyz\n")
+
+	if got := b.ReadString('\n'); got != "tuvwxyz\n" {
+		t.Errorf("Expected newest output to be kept, got %q instead", got)
+	}
+
+	if b.Dropped() != 15 {
+		t.Errorf("Expected 15 dropped bytes, got %d instead", b.Dropped())
+	}
+}
+
+func TestOutputBufferFail(t *testing.T) {
+	var b = newOutputBuffer(4, OverflowFail)
+
+	if n, err := io.WriteString(b, "abcdef"); n != 4 || err != ErrBufferFull {
+		t.Errorf("Expected (4, %v), got (%v, %v) instead", ErrBufferFull, n, err)
+	}
+
+	if b.Err() != ErrBufferFull {
+		t.Errorf("Expected error to be %v, got %v instead", ErrBufferFull, b.Err())
+	}
+}
+
+func TestOutputBufferBlock(t *testing.T) {
+	var b = newOutputBuffer(4, OverflowBlock)
+	var done = make(chan struct{})
+
+	go func() {
+		_, _ = io.WriteString(b, "ab\ncd\nef\n")
+		close(done)
+	}()
+
+	var got string
+
+	for len(got) < 9 {
+		got += b.ReadString('\n')
+	}
+
+	<-done
+
+	if got != "ab\ncd\nef\n" {
+		t.Errorf("Expected all output, got %q instead", got)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.Close()
+	}()
+
+	// blocks until closed
+	if n, err := io.WriteString(b, "123456"); n != 6 || err != nil {
+		t.Errorf("Expected closed buffer to discard writes, got (%v, %v) instead", n, err)
+	}
+}
+
+func TestTerminalBufferOverflow(t *testing.T) {
+	var spew = &Program{
+		Func: func(stdin io.Reader, stdout io.Writer) int {
+			_, _ = io.WriteString(stdout, strings.Repeat("y\n", 1000))
+			return 0
+		},
+	}
+
+	var term = &Terminal{
+		Transport:  spew,
+		BufferSize: 16,
+		Overflow:   OverflowFail,
+	}
+
+	var story = &QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(Step{
+		Read:      "never printed",
+		SkipWrite: true,
+	})
+
+	if err := term.Run(story); err == nil || !strings.Contains(err.Error(), ErrBufferFull.Error()) {
+		t.Errorf("Expected error to be %v, got %v instead", ErrBufferFull, err)
+	}
+}
+
+func TestTerminalSkipBuffer(t *testing.T) {
+	var term = &Terminal{
+		Transport: &Program{
+			Func: func(stdin io.Reader, stdout io.Writer) int {
+				_, _ = io.WriteString(stdout, strings.Repeat("y\n", 1000))
+				return 0
+			},
+		},
+		BufferSize: 16,
+		SkipBuffer: true,
+	}
+
+	if err := term.Run(&QueueStory{}); err == nil || !strings.Contains(err.Error(), errSkipBuffer.Error()) {
+		t.Errorf("Expected error to be %v, got %v instead", errSkipBuffer, err)
+	}
+
+	if n := term.bfs.Len(); n != 0 {
+		t.Errorf("Expected output to not be buffered, got %d bytes instead", n)
+	}
+}
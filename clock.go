@@ -0,0 +1,213 @@
+package pseudoterm
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the time and waits for durations to pass.
+// Use a FakeClock to test timeouts without waiting for them.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer fires once on C, like time.Timer.
+// Stop it when you don't need it anymore, so the clock forgets about it.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// SystemClock is the Clock reading the system time
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (s systemTimer) C() <-chan time.Time {
+	return s.t.C
+}
+
+func (s systemTimer) Stop() bool {
+	return s.t.Stop()
+}
+
+// FakeClock is a Clock whose time only passes when advanced.
+// The zero value starts at the zero time.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+type fakeTimer struct {
+	f     *FakeClock
+	until time.Time
+	c     chan time.Time
+}
+
+// NewFakeClock creates a FakeClock starting at the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now: now,
+	}
+}
+
+// Now returns the time of the clock
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After returns a channel receiving the time once the clock is advanced by d
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer creates a Timer firing once the clock is advanced by d
+func (f *FakeClock) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var w = &fakeTimer{
+		f:     f,
+		until: f.now.Add(d),
+		c:     make(chan time.Time, 1),
+	}
+
+	if d <= 0 {
+		w.c <- f.now
+		return w
+	}
+
+	f.waiters = append(f.waiters, w)
+	f.signal().Broadcast()
+	return w
+}
+
+func (w *fakeTimer) C() <-chan time.Time {
+	return w.c
+}
+
+// Stop the timer, removing it from the waiters of the clock.
+// It returns false if the timer has already fired or been stopped.
+func (w *fakeTimer) Stop() bool {
+	var f = w.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for c, waiter := range f.waiters {
+		if waiter == w {
+			f.waiters = append(f.waiters[:c], f.waiters[c+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Advance the clock, firing the waiters whose time has come
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	var waiters = f.waiters[:0]
+
+	for _, w := range f.waiters {
+		if w.until.After(f.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+
+		w.c <- f.now
+	}
+
+	f.waiters = waiters
+}
+
+// BlockUntil blocks until there are at least n timers waiting on the clock,
+// so you know the code you are testing is waiting before advancing it
+func (f *FakeClock) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.waiters) < n {
+		f.signal().Wait()
+	}
+}
+
+func (f *FakeClock) signal() *sync.Cond {
+	if f.cond == nil {
+		f.cond = sync.NewCond(&f.mu)
+	}
+
+	return f.cond
+}
+
+// timeoutCtx is a context cancelled when the duration passes on the clock
+type timeoutCtx struct {
+	context.Context
+	deadline time.Time
+	expired  int32
+}
+
+func (c *timeoutCtx) Deadline() (deadline time.Time, ok bool) {
+	return c.deadline, true
+}
+
+func (c *timeoutCtx) Err() error {
+	if atomic.LoadInt32(&c.expired) == 1 {
+		return context.DeadlineExceeded
+	}
+
+	return c.Context.Err()
+}
+
+// withTimeout is like context.WithTimeout, but using the given clock
+func withTimeout(parent context.Context, clock Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	if clock == SystemClock {
+		return context.WithTimeout(parent, d)
+	}
+
+	var ctx, cancel = context.WithCancel(parent)
+	var c = &timeoutCtx{
+		Context:  ctx,
+		deadline: clock.Now().Add(d),
+	}
+
+	var timer = clock.NewTimer(d)
+
+	go func() {
+		select {
+		case <-timer.C():
+			atomic.StoreInt32(&c.expired, 1)
+			cancel()
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}()
+
+	return c, cancel
+}
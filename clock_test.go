@@ -0,0 +1,268 @@
+package pseudoterm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+var fakeClockStart = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClock(t *testing.T) {
+	var clock = NewFakeClock(fakeClockStart)
+
+	if now := clock.Now(); !now.Equal(fakeClockStart) {
+		t.Errorf("Expected time to be %v, got %v instead", fakeClockStart, now)
+	}
+
+	select {
+	case <-clock.After(0):
+	default:
+		t.Errorf("Expected After(0) to fire immediately")
+	}
+
+	var blocked = make(chan struct{})
+
+	go func() {
+		clock.BlockUntil(2)
+		close(blocked)
+	}()
+
+	var second = clock.After(time.Second)
+	var minute = clock.After(time.Minute)
+
+	<-blocked
+
+	clock.Advance(999 * time.Millisecond)
+
+	select {
+	case <-second:
+		t.Errorf("Expected clock to not fire before time")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+
+	select {
+	case now := <-second:
+		if want := fakeClockStart.Add(time.Second); !now.Equal(want) {
+			t.Errorf("Expected time to be %v, got %v instead", want, now)
+		}
+	default:
+		t.Errorf("Expected clock to fire after a second")
+	}
+
+	select {
+	case <-minute:
+		t.Errorf("Expected clock to not fire before a minute")
+	default:
+	}
+}
+
+func TestFakeClockTimerStop(t *testing.T) {
+	var clock = NewFakeClock(fakeClockStart)
+	var timer = clock.NewTimer(time.Second)
+
+	if !timer.Stop() {
+		t.Errorf("Expected timer to be stopped")
+	}
+
+	if timer.Stop() {
+		t.Errorf("Expected timer to be stopped already")
+	}
+
+	if n := fakeClockWaiters(clock); n != 0 {
+		t.Errorf("Expected no waiters, got %v instead", n)
+	}
+
+	clock.Advance(time.Second)
+
+	select {
+	case <-timer.C():
+		t.Errorf("Expected stopped timer to not fire")
+	default:
+	}
+}
+
+func TestWithTimeoutCancelStopsTimer(t *testing.T) {
+	var clock = NewFakeClock(fakeClockStart)
+	var _, cancel = withTimeout(context.Background(), clock, time.Hour)
+
+	clock.BlockUntil(1)
+	cancel()
+
+	var deadline = time.Now().Add(5 * time.Second)
+
+	for fakeClockWaiters(clock) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected timer of the cancelled context to be stopped")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTerminalSleepStopsTimer(t *testing.T) {
+	var clock = NewFakeClock(fakeClockStart)
+	var term = &Terminal{
+		Clock: clock,
+	}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	term.sleep(ctx)
+
+	if n := fakeClockWaiters(clock); n != 0 {
+		t.Errorf("Expected timer to be stopped, got %v waiters instead", n)
+	}
+}
+
+func fakeClockWaiters(clock *FakeClock) int {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return len(clock.waiters)
+}
+
+func TestStoryTimeoutWithFakeClock(t *testing.T) {
+	var clock = NewFakeClock(fakeClockStart)
+	var story = &QueueStory{
+		Timeout: time.Hour,
+		Clock:   clock,
+	}
+
+	var ctx, err = story.Setup()
+
+	if err != nil {
+		t.Fatalf("Expected story setup to be fine, got %v error instead", err)
+	}
+
+	defer story.Teardown()
+
+	if deadline, ok := ctx.Deadline(); !ok || !deadline.Equal(fakeClockStart.Add(time.Hour)) {
+		t.Errorf("Expected deadline to be in an hour, got %v instead", deadline)
+	}
+
+	clock.Advance(59 * time.Minute)
+
+	if ctx.Err() != nil {
+		t.Errorf("Expected context to not be done yet, got %v instead", ctx.Err())
+	}
+
+	clock.Advance(time.Minute)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("Expected context to be done after advancing clock")
+	}
+
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("Expected context error to be %v, got %v instead",
+			context.DeadlineExceeded,
+			ctx.Err())
+	}
+}
+
+func TestStepTimeoutWithFakeClock(t *testing.T) {
+	var clock = NewFakeClock(fakeClockStart)
+	var story = &QueueStory{
+		Clock: clock,
+	}
+
+	story.Add(Step{
+		Read:    "Select from 1..2:",
+		Write:   "2",
+		Timeout: 10 * time.Second,
+	})
+
+	if _, err := story.Setup(); err != nil {
+		t.Fatalf("Expected story setup to be fine, got %v error instead", err)
+	}
+
+	defer story.Teardown()
+
+	clock.Advance(9 * time.Second)
+
+	if err := story.TickHandler(); err != nil {
+		t.Errorf("Expected no error before timeout, got %v instead", err)
+	}
+
+	clock.Advance(time.Second)
+
+	var wantErr = `Timed out while waiting for line "Select from 1..2:": timeout 10s`
+
+	if err := story.TickHandler(); err == nil || err.Error() != wantErr {
+		t.Errorf("Wanted err to be %v, got %v instead", wantErr, err)
+	}
+}
+
+func TestTerminalWithFakeClock(t *testing.T) {
+	var clock = NewFakeClock(fakeClockStart)
+	var term = &Terminal{
+		Transport: &Program{
+			Func: func(stdin io.Reader, stdout io.Writer) int {
+				var r = bufio.NewReader(stdin)
+				fmt.Fprint(stdout, "Your name: ")
+				name, _ := r.ReadString('\n')
+				fmt.Fprintf(stdout, "Hi, %s", name)
+				_, _ = ioutil.ReadAll(r)
+				return 0
+			},
+		},
+		Clock: clock,
+	}
+
+	var story = &QueueStory{
+		Timeout: time.Hour,
+		Clock:   clock,
+	}
+
+	story.Add(
+		Step{
+			Read:  "Your name:",
+			Write: "Henrique",
+		},
+		Step{
+			Read:    "Your age:",
+			Write:   "10",
+			Timeout: time.Minute,
+		})
+
+	var done = make(chan error, 1)
+
+	go func() {
+		done <- term.Run(story)
+	}()
+
+	var err error
+
+loop:
+	for {
+		select {
+		case err = <-done:
+			break loop
+		case <-time.After(time.Millisecond):
+			clock.Advance(time.Second)
+		}
+	}
+
+	var wantErr = `Run error: Timed out while waiting for line "Your age:": timeout 1m0s`
+
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Unexpected error: wanted %v, got %v instead", wantErr, err)
+	}
+
+	if len(story.Sequence) != 1 {
+		t.Errorf("Expected one step left, got %v instead", len(story.Sequence))
+	}
+
+	if elapsed := clock.Now().Sub(fakeClockStart); elapsed < time.Minute || elapsed >= time.Hour {
+		t.Errorf("Expected step timeout to happen after a minute, got %v instead", elapsed)
+	}
+
+	term.Wait()
+}
@@ -0,0 +1,326 @@
+/*
+Command pseudoterm runs story files against interactive programs.
+
+	pseudoterm [flags] story.yaml [story.yaml...]
+
+Starlark scripts (see package script) are also supported,
+with the program to run given after "--":
+
+	pseudoterm [flags] login.star -- ./program [args...]
+
+A command given after "--" also overrides the command of story files.
+The output of the programs is printed as they run, followed by a summary when
+running more than one story. It exits with status 0 when all stories succeed,
+1 when any of them fails, and 2 on usage or story file errors.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+	"github.com/henvic/pseudoterm/script"
+	"github.com/henvic/pseudoterm/storyfile"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+type runner struct {
+	timeout     time.Duration
+	stepTimeout time.Duration
+	rows        uint
+	cols        uint
+	quiet       bool
+	debug       bool
+	transcript  string
+
+	stdout io.Writer
+	stderr io.Writer
+	log    io.Writer
+}
+
+type result struct {
+	filename string
+	elapsed  time.Duration
+	err      error
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	var r = &runner{
+		stdout: stdout,
+		stderr: stderr,
+	}
+
+	var flags = flag.NewFlagSet("pseudoterm", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.DurationVar(&r.timeout, "timeout", 0, "story timeout (overrides the story files timeout)")
+	flags.DurationVar(&r.stepTimeout, "step-timeout", 0, "timeout for steps and script expect calls without one")
+	flags.UintVar(&r.rows, "rows", 0, "number of rows of the terminal")
+	flags.UintVar(&r.cols, "cols", 0, "number of columns of the terminal")
+	flags.BoolVar(&r.quiet, "q", false, "don't print the output of the programs")
+	flags.BoolVar(&r.debug, "debug", false, "pause before each step of story files, reading commands from the terminal")
+	flags.StringVar(&r.transcript, "transcript", "", "save the output of the programs on the given file")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: pseudoterm [flags] story.yaml|script.star [...] [-- command [args...]]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 || flags.Arg(0) == "--" || r.rows > 0xffff || r.cols > 0xffff {
+		flags.Usage()
+		return 2
+	}
+
+	var files, command = splitCommand(flags.Args())
+	var jobs, code = r.load(files, command)
+
+	if code != 0 {
+		return code
+	}
+
+	if r.transcript != "" {
+		var f, err = os.Create(r.transcript)
+
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+
+		defer f.Close()
+		r.log = f
+	}
+
+	var results []result
+
+	for _, j := range jobs {
+		results = append(results, r.runJob(j))
+	}
+
+	return r.summary(results)
+}
+
+// splitCommand splits the arguments at "--"
+func splitCommand(args []string) (files, command []string) {
+	for c, a := range args {
+		if a == "--" {
+			return args[:c], args[c+1:]
+		}
+	}
+
+	return args, nil
+}
+
+type job struct {
+	filename string
+	term     *pseudoterm.Terminal
+	story    pseudoterm.Story
+	check    func() error
+}
+
+func (r *runner) load(filenames, command []string) (jobs []job, code int) {
+	for _, filename := range filenames {
+		var j, err = r.loadJob(filename, command)
+
+		if err != nil {
+			fmt.Fprintln(r.stderr, err)
+			code = 2
+			continue
+		}
+
+		jobs = append(jobs, j)
+	}
+
+	return jobs, code
+}
+
+func (r *runner) loadJob(filename string, command []string) (job, error) {
+	if filepath.Ext(filename) == ".star" {
+		return r.loadScript(filename, command)
+	}
+
+	var f, err = storyfile.Load(filename)
+
+	if err != nil {
+		return job{}, err
+	}
+
+	if len(command) != 0 {
+		f.Command, f.Args = command[0], command[1:]
+	}
+
+	var term, story = f.Terminal(), f.Story()
+
+	if r.timeout != 0 {
+		story.Timeout = r.timeout
+	}
+
+	for c := range story.Sequence {
+		if story.Sequence[c].Timeout == 0 {
+			story.Sequence[c].Timeout = r.stepTimeout
+		}
+	}
+
+	var s pseudoterm.Story = story
+
+	if r.debug {
+		s = &pseudoterm.Debugger{
+			Story:    story,
+			Terminal: term,
+		}
+	}
+
+	return job{
+		filename: filename,
+		term:     term,
+		story:    s,
+		check: func() error {
+			if !story.Success() {
+				return fmt.Errorf("story didn't succeed: %d steps left", len(story.Sequence))
+			}
+
+			return nil
+		},
+	}, nil
+}
+
+func (r *runner) loadScript(filename string, command []string) (job, error) {
+	if len(command) == 0 {
+		return job{}, fmt.Errorf("%s: scripts require a command after --", filename)
+	}
+
+	if r.debug {
+		return job{}, fmt.Errorf("%s: -debug is only supported for story files", filename)
+	}
+
+	var source, err = os.ReadFile(filename)
+
+	if err != nil {
+		return job{}, err
+	}
+
+	var term = &pseudoterm.Terminal{
+		Command: exec.Command(command[0], command[1:]...),
+	}
+
+	var story = &script.Story{
+		Terminal:      term,
+		Filename:      filename,
+		Source:        source,
+		Timeout:       r.timeout,
+		ExpectTimeout: r.stepTimeout,
+		Print:         r.stderr,
+	}
+
+	return job{
+		filename: filename,
+		term:     term,
+		story:    story,
+		check: func() error {
+			if !story.Success() {
+				return fmt.Errorf("script didn't succeed: %v", story.Err())
+			}
+
+			return nil
+		},
+	}, nil
+}
+
+func (r *runner) runJob(j job) result {
+	var term = j.term
+	var out []io.Writer
+
+	if !r.quiet {
+		out = append(out, r.stdout)
+	}
+
+	if r.log != nil {
+		fmt.Fprintf(r.log, "=== %s\n", j.filename)
+		out = append(out, r.log)
+	}
+
+	term.EchoStream = io.MultiWriter(out...)
+
+	if term.SeparateStderr {
+		var errOut = []io.Writer{}
+
+		if !r.quiet {
+			errOut = append(errOut, r.stderr)
+		}
+
+		if r.log != nil {
+			errOut = append(errOut, r.log)
+		}
+
+		term.StderrEchoStream = io.MultiWriter(errOut...)
+	}
+
+	term.Rows, term.Cols = uint16(r.rows), uint16(r.cols)
+
+	var start = time.Now()
+	var err = term.Run(j.story)
+
+	if err == nil {
+		err = j.check()
+	}
+
+	if err == nil {
+		if ps := term.Wait(); ps != nil && !ps.Success() {
+			err = errors.New("program exited with " + ps.String())
+		}
+	}
+
+	return result{
+		filename: j.filename,
+		elapsed:  time.Since(start),
+		err:      err,
+	}
+}
+
+func (r *runner) summary(results []result) int {
+	var failed int
+
+	for _, res := range results {
+		if res.err != nil {
+			failed++
+		}
+	}
+
+	if len(results) == 1 {
+		if err := results[0].err; err != nil {
+			fmt.Fprintf(r.stderr, "\n%s: %v\n", results[0].filename, err)
+			return 1
+		}
+
+		return 0
+	}
+
+	fmt.Fprintln(r.stderr)
+
+	for _, res := range results {
+		if res.err != nil {
+			fmt.Fprintf(r.stderr, "FAIL %s (%v): %v\n", res.filename, res.elapsed.Round(time.Millisecond), res.err)
+		} else {
+			fmt.Fprintf(r.stderr, "PASS %s (%v)\n", res.filename, res.elapsed.Round(time.Millisecond))
+		}
+	}
+
+	fmt.Fprintf(r.stderr, "%d passed, %d failed\n", len(results)-failed, failed)
+
+	if failed != 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,164 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	if code := run([]string{"testdata/pass.yaml"}, &stdout, &stderr); code != 0 {
+		t.Errorf("Expected exit code 0, got %v instead (%s)", code, stderr.String())
+	}
+
+	if !strings.Contains(stdout.String(), "Your age is 10") {
+		t.Errorf("Expected program output, got %q instead", stdout.String())
+	}
+
+	if stderr.Len() != 0 {
+		t.Errorf("Expected no summary for a single story, got %q instead", stderr.String())
+	}
+}
+
+func TestRunMany(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	var transcript = filepath.Join(t.TempDir(), "transcript.log")
+
+	var code = run([]string{
+		"-q",
+		"-transcript", transcript,
+		"testdata/pass.yaml",
+		"testdata/fail.yaml",
+	}, &stdout, &stderr)
+
+	if code != 1 {
+		t.Errorf("Expected exit code 1, got %v instead", code)
+	}
+
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no output on quiet mode, got %q instead", stdout.String())
+	}
+
+	var summary = stderr.String()
+
+	for _, want := range []string{
+		"PASS testdata/pass.yaml",
+		"FAIL testdata/fail.yaml",
+		"story didn't succeed: 1 steps left",
+		"1 passed, 1 failed",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Expected summary to contain %q, got %q instead", want, summary)
+		}
+	}
+
+	var log, err = os.ReadFile(transcript)
+
+	if err != nil {
+		t.Fatalf("Expected no error reading transcript, got %v instead", err)
+	}
+
+	for _, want := range []string{"=== testdata/pass.yaml", "Your age is 10", "=== testdata/fail.yaml", "Wait..."} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("Expected transcript to contain %q, got %q instead", want, log)
+		}
+	}
+}
+
+func TestRunFlags(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	var code = run([]string{
+		"-rows", "30",
+		"-cols", "90",
+		"-timeout", "2s",
+		"-step-timeout", "1s",
+		"testdata/size.yaml",
+	}, &stdout, &stderr)
+
+	if code != 0 {
+		t.Errorf("Expected exit code 0, got %v instead (%s)", code, stderr.String())
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	var code = run([]string{
+		"-step-timeout", "1s",
+		"testdata/login.star",
+		"--",
+		"../../mocks/mock-login.sh",
+	}, &stdout, &stderr)
+
+	if code != 0 {
+		t.Errorf("Expected exit code 0, got %v instead (%s)", code, stderr.String())
+	}
+
+	if want := "token: abc\n"; stderr.String() != want {
+		t.Errorf("Expected script to print %q, got %q instead", want, stderr.String())
+	}
+}
+
+func TestRunDebugScript(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	var code = run([]string{
+		"-debug",
+		"testdata/login.star",
+		"--",
+		"../../mocks/mock-login.sh",
+	}, &stdout, &stderr)
+
+	if code != 2 {
+		t.Errorf("Expected exit code 2, got %v instead", code)
+	}
+
+	if want := "testdata/login.star: -debug is only supported for story files\n"; stderr.String() != want {
+		t.Errorf("Expected error %q, got %q instead", want, stderr.String())
+	}
+}
+
+func TestRunCommandOverride(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	var code = run([]string{
+		"testdata/fail.yaml",
+		"--",
+		"bash", "-c", "read -p 'Your name: ' NAME; echo Hi $NAME",
+	}, &stdout, &stderr)
+
+	if code != 0 {
+		t.Errorf("Expected exit code 0, got %v instead (%s)", code, stderr.String())
+	}
+
+	if !strings.Contains(stdout.String(), "Hi Henrique") {
+		t.Errorf("Unexpected output %q", stdout.String())
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	var cases = [][]string{
+		[]string{},
+		[]string{"-rows", "70000", "testdata/pass.yaml"},
+		[]string{"-unknown", "testdata/pass.yaml"},
+		[]string{"testdata/not-found.yaml"},
+		[]string{"--", "cat"},
+		[]string{"testdata/login.star"},
+	}
+
+	for _, args := range cases {
+		var stdout, stderr bytes.Buffer
+
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("Expected exit code 2 for %v, got %v instead", args, code)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package pseudoterm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrDebugAbort is returned when aborting a story on the Debugger
+var ErrDebugAbort = errors.New("Aborted by the debugger")
+
+// DebugLines is the number of recent lines of output shown by the Debugger, by default
+var DebugLines = 10
+
+// Debugger of a QueueStory, pausing before each step to show it with the recent
+// output and variables, and reading commands from In:
+//
+//	c, continue (or empty)  run the step
+//	s, skip                 skip the step
+//	t, type <input>         write a line to the program
+//	r, run                  run the remaining steps without pausing
+//	a, abort                abort the story, returning ErrDebugAbort
+//
+// In and Out default to the controlling terminal (/dev/tty).
+// Vars returns additional variables to show, if set.
+// The story Timeout is disabled, as pauses would count against it,
+// and step timeouts start counting after resuming.
+type Debugger struct {
+	Story    *QueueStory
+	Terminal *Terminal
+	In       io.Reader
+	Out      io.Writer
+	Vars     func() map[string]string
+	Lines    int
+
+	in      *bufio.Reader
+	out     io.Writer
+	tty     *os.File
+	total   int
+	paused  int
+	running bool
+	recent  []string
+}
+
+// Setup the debugger and the story
+func (d *Debugger) Setup() (ctx context.Context, err error) {
+	if d.In == nil || d.Out == nil {
+		if d.tty, err = os.OpenFile("/dev/tty", os.O_RDWR, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	var in, out = d.In, d.Out
+
+	if in == nil {
+		in = d.tty
+	}
+
+	if out == nil {
+		out = d.tty
+	}
+
+	d.in, d.out = bufio.NewReader(in), out
+	d.total = len(d.Story.Sequence)
+	d.Story.Timeout = 0
+	return d.Story.Setup()
+}
+
+// Teardown the story and the debugger
+func (d *Debugger) Teardown() {
+	d.Story.Teardown()
+
+	if d.tty != nil {
+		_ = d.tty.Close()
+	}
+}
+
+// TickHandler pauses before each step, then calls the story TickHandler
+func (d *Debugger) TickHandler() error {
+	for !d.running && len(d.Story.Sequence) != 0 && d.step() > d.paused {
+		d.paused = d.step()
+
+		if err := d.pause(); err != nil {
+			return err
+		}
+	}
+
+	return d.Story.TickHandler()
+}
+
+// HandleLine handles a line the program prints
+func (d *Debugger) HandleLine(s string) (in string, err error) {
+	return d.HandleStreamLine(s, AnyStream)
+}
+
+// HandleStreamLine handles a line the program prints on a given stream
+func (d *Debugger) HandleStreamLine(s string, stream OutputStream) (in string, err error) {
+	d.recent = append(d.recent, strings.TrimRight(s, "\r\n"))
+
+	if n := d.lines(); len(d.recent) > n {
+		d.recent = d.recent[len(d.recent)-n:]
+	}
+
+	return d.Story.HandleStreamLine(s, stream)
+}
+
+// NeedsState tells if the current step matches the state of the terminal
+func (d *Debugger) NeedsState() bool {
+	return d.Story.NeedsState()
+}
+
+// HandleState handles the state of the terminal
+func (d *Debugger) HandleState(s State) (in string, err error) {
+	return d.Story.HandleState(s)
+}
+
+// step number of the current step, starting at 1
+func (d *Debugger) step() int {
+	return d.total - len(d.Story.Sequence) + 1
+}
+
+func (d *Debugger) lines() int {
+	if d.Lines == 0 {
+		return DebugLines
+	}
+
+	return d.Lines
+}
+
+func (d *Debugger) pause() error {
+	d.show()
+
+	for {
+		fmt.Fprint(d.out, "[c]ontinue, [s]kip, [t]ype <input>, [r]un, [a]bort> ")
+		var line, err = d.in.ReadString('\n')
+
+		// without more commands, the story runs until the end
+		if err == io.EOF && line == "" {
+			fmt.Fprintln(d.out)
+			d.running = true
+			return nil
+		}
+
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		var cmd, arg = line, ""
+
+		if i := strings.IndexAny(line, " \t"); i != -1 {
+			cmd, arg = line[:i], strings.TrimRight(line[i+1:], "\r\n")
+		}
+
+		switch strings.TrimSpace(cmd) {
+		case "", "c", "continue":
+			d.Story.pastStepTime = d.Story.clock().Now()
+			return nil
+		case "s", "skip":
+			fmt.Fprintf(d.out, "Skipped step %d\n", d.step())
+			d.Story.armed = false
+			d.Story.shift()
+			d.Story.pastStepTime = d.Story.clock().Now()
+			return nil
+		case "t", "type":
+			if _, err := d.Terminal.WriteLine(arg); err != nil {
+				return err
+			}
+		case "r", "run":
+			d.running = true
+			d.Story.pastStepTime = d.Story.clock().Now()
+			return nil
+		case "a", "abort":
+			return ErrDebugAbort
+		default:
+			fmt.Fprintf(d.out, "Unknown command %q\n", strings.TrimSpace(line))
+		}
+	}
+}
+
+func (d *Debugger) show() {
+	var step = d.Story.Sequence[0]
+	fmt.Fprintf(d.out, "\n--- step %d/%d: %v\n", d.step(), d.total, describeStep(step))
+	fmt.Fprintln(d.out, "recent output:")
+
+	for _, l := range d.recent {
+		fmt.Fprintf(d.out, "  %s\n", l)
+	}
+
+	var vars = map[string]string{
+		"steps left": fmt.Sprint(len(d.Story.Sequence)),
+		"elapsed":    d.Story.clock().Now().Sub(d.Story.pastStepTime).Round(time.Millisecond).String(),
+	}
+
+	if s, err := d.Terminal.State(); err == nil {
+		vars["echo"] = fmt.Sprint(s.Mode.Echo)
+		vars["input requested"] = fmt.Sprint(s.InputRequested)
+		vars["foreground"] = s.Foreground.Command
+	}
+
+	if d.Vars != nil {
+		for k, v := range d.Vars() {
+			vars[k] = v
+		}
+	}
+
+	var keys []string
+
+	for k := range vars {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	fmt.Fprintln(d.out, "variables:")
+
+	for _, k := range keys {
+		fmt.Fprintf(d.out, "  %s = %s\n", k, vars[k])
+	}
+}
+
+func describeStep(s Step) string {
+	var d = s.description()
+
+	switch {
+	case s.SkipWrite:
+		d += ", then skip writing"
+	case len(s.Keys) != 0:
+		d += fmt.Sprintf(", then press %v", strings.Join(s.Keys, " "))
+	case s.Secret:
+		d += ", then write a secret"
+	default:
+		d += fmt.Sprintf(", then write %q", s.Write)
+	}
+
+	if s.Timeout != 0 {
+		d += fmt.Sprintf(" (timeout %v)", s.Timeout)
+	}
+
+	return d
+}
@@ -0,0 +1,112 @@
+//go:build !windows
+// +build !windows
+
+package pseudoterm
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugger(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var term = &Terminal{
+		Command:    exec.Command("mocks/mock.sh"),
+		EchoStream: echoStream,
+	}
+
+	var story = &QueueStory{
+		Timeout: time.Millisecond,
+	}
+
+	story.Add(
+		Step{
+			Read:  "Your name:",
+			Write: "Henrique",
+		},
+		Step{
+			Read:  "Your age:",
+			Write: "99",
+		},
+		Step{
+			Read:      "Bye!",
+			SkipWrite: true,
+		})
+
+	var out = &bytes.Buffer{}
+	var debugger = &Debugger{
+		Story:    story,
+		Terminal: term,
+		In:       strings.NewReader("\nwhat\ns\nt 10\nc\n"),
+		Out:      out,
+		Vars: func() map[string]string {
+			return map[string]string{"user": "henvic"}
+		},
+	}
+
+	if err := term.Run(debugger); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success: %+v\n%q", story.Sequence, echoStream.String())
+	}
+
+	for _, want := range []string{
+		`--- step 1/3: line "Your name:", then write "Henrique"`,
+		`Unknown command "what"`,
+		"Skipped step 2",
+		`--- step 3/3: line "Bye!", then skip writing`,
+		"  Starting\n",
+		"  user = henvic\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected debugger output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestDebuggerAbort(t *testing.T) {
+	var term = &Terminal{
+		Command: exec.Command("mocks/mock.sh"),
+	}
+
+	var story = &QueueStory{}
+	story.Add(Step{
+		Read:  "Your name:",
+		Write: "Henrique",
+	})
+
+	var debugger = &Debugger{
+		Story:    story,
+		Terminal: term,
+		In:       strings.NewReader("abort\n"),
+		Out:      &bytes.Buffer{},
+	}
+
+	var err = term.Run(debugger)
+
+	if ee, ok := err.(ExecutionError); !ok || ee.RunError != ErrDebugAbort {
+		t.Errorf("Expected run error to be %v, got %v instead", ErrDebugAbort, err)
+	}
+}
+
+func TestDescribeStep(t *testing.T) {
+	var cases = []struct {
+		step Step
+		want string
+	}{
+		{Step{Read: "Name:", Write: "x", Timeout: time.Second}, `line "Name:", then write "x" (timeout 1s)`},
+		{Step{NoEcho: true, Keys: []string{"down", "enter"}}, "echo disabled, then press down enter"},
+		{Step{Read: "Continue?", InputRequested: true, SkipWrite: true}, `line "Continue?" and input request, then skip writing`},
+	}
+
+	for _, c := range cases {
+		if got := describeStep(c.step); got != c.want {
+			t.Errorf("Expected description to be %q, got %q instead", c.want, got)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/henvic/pseudoterm"
+	"github.com/henvic/pseudoterm/storyfile"
+)
+
+var (
+	transcript = flag.String("transcript", "", "save the transcript as JSON on the given file")
+	story      = flag.String("story", "", "save a story file on the given file")
+)
+
+// Usage: record [-transcript file.json] [-story story.yaml] ./program [args...] 2> story.go
+// Interact with the program as usual: once it ends the story is printed to stderr
+func main() {
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: record [-transcript file.json] [-story story.yaml] command [args...]")
+		os.Exit(2)
+	}
+
+	var r = &pseudoterm.Recorder{
+		Terminal: &pseudoterm.Terminal{
+			Command: exec.Command(flag.Arg(0), flag.Args()[1:]...),
+		},
+	}
+
+	var tr, err = r.Record()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := tr.WriteGo(os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *transcript != "" {
+		if err := save(*transcript, tr.WriteJSON); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if *story != "" {
+		var sf = storyfile.FromTranscript(tr, flag.Arg(0), flag.Args()[1:]...)
+
+		if err := save(*story, sf.WriteYAML); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func save(name string, write func(w io.Writer) error) error {
+	var f, err = os.Create(name)
+
+	if err != nil {
+		return err
+	}
+
+	if err = write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
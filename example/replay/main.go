@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/henvic/pseudoterm"
+)
+
+// Usage: replay transcript.json
+// Plays the program recorded on the transcript (see example/record)
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: replay transcript.json")
+		os.Exit(2)
+	}
+
+	var f, err = os.Open(os.Args[1])
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	tr, err := pseudoterm.ReadTranscript(f)
+	_ = f.Close()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var r = &pseudoterm.Replayer{
+		Transcript: tr,
+	}
+
+	if err := r.Replay(); err != nil {
+		fmt.Fprintf(os.Stderr, "\nreplay: %v\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(tr.ExitCode)
+}
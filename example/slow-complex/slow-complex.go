@@ -53,5 +53,6 @@ func main() {
 		println(err.Error())
 	}
 
-	fmt.Fprintf(os.Stdout, "\nStory executed successfully: %v\n", story.Success())
+	fmt.Fprintf(os.Stdout, "\nRandom number: %v\n", numFromStep)
+	fmt.Fprintf(os.Stdout, "Story executed successfully: %v\n", story.Success())
 }
@@ -0,0 +1,45 @@
+//go:build !windows
+// +build !windows
+
+package pseudoterm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// WaitForOutput is shared with the external tests
+var WaitForOutput = waitForOutput
+
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func waitForOutput(t *testing.T, s fmt.Stringer, want string) {
+	var deadline = time.Now().Add(5 * time.Second)
+
+	for !strings.Contains(s.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %q, got %q instead", want, s.String())
+		}
+
+		time.Sleep(LineReaderInterval)
+	}
+}
@@ -0,0 +1,28 @@
+package pseudoterm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// foreground process group of the pseudo tty and the command line of its leader
+func foreground(fd uintptr) (f Foreground, err error) {
+	if f.Pgid, err = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP); err != nil {
+		return f, err
+	}
+
+	// the leader might be gone while other processes of the group are still running
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", f.Pgid))
+
+	if err != nil {
+		return f, nil
+	}
+
+	var args = bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0})
+	f.Program = programName(string(args[0]))
+	f.Command = string(bytes.Join(args, []byte(" ")))
+	return f, nil
+}
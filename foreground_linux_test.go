@@ -0,0 +1,96 @@
+package pseudoterm
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestTerminalForeground(t *testing.T) {
+	var cmd = exec.Command("bash", "--norc", "--noprofile", "-i")
+	cmd.Env = append(os.Environ(), "PS1=$ ")
+
+	var term = &Terminal{
+		Command: cmd,
+	}
+
+	var states = make(chan State, 100)
+	term.NotifyState(states)
+
+	var story = &QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(
+		Step{
+			Foreground:     "bash",
+			InputRequested: true,
+			Write:          "sleep 0.3",
+		},
+		Step{
+			Foreground: "sleep 0.3",
+			SkipWrite:  true,
+		},
+		Step{
+			Foreground: "bash",
+			Write:      "exit",
+		})
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success: %+v", story.Sequence)
+	}
+
+	close(states)
+	var programs []string
+
+	for s := range states {
+		// a forked process might be in the foreground before running the command
+		if p := s.Foreground.Program; p != "" && (len(programs) == 0 || programs[len(programs)-1] != p) {
+			programs = append(programs, p)
+		}
+	}
+
+	if len(programs) < 3 || programs[0] != "bash" || programs[1] != "sleep" || programs[2] != "bash" {
+		t.Errorf("Expected foreground to change from bash to sleep and back, got %v instead", programs)
+	}
+}
+
+func TestStoryForegroundStep(t *testing.T) {
+	var story = &QueueStory{}
+
+	story.Add(Step{
+		Read:       "$ ",
+		Foreground: "bash",
+		Write:      "ls",
+	})
+
+	if _, err := story.HandleState(State{Foreground: Foreground{Program: "vim", Command: "vim"}}); err != SkipWrite {
+		t.Errorf("Expected step with Read to not match on state, got %v instead", err)
+	}
+
+	if _, err := story.HandleLine("$ "); err != SkipWrite {
+		t.Errorf("Expected step to not match while another program is in the foreground, got %v instead", err)
+	}
+
+	if in, err := story.HandleState(State{Foreground: Foreground{Program: "bash", Command: "-bash"}}); in != "ls" || err != nil {
+		t.Errorf("Expected step to match, got (%v, %v) instead", in, err)
+	}
+}
+
+func TestProgramName(t *testing.T) {
+	for arg0, want := range map[string]string{
+		"/bin/bash": "bash",
+		"-bash":     "bash",
+		"":          "",
+		"sleep":     "sleep",
+	} {
+		if got := programName(arg0); got != want {
+			t.Errorf("Expected program name of %v to be %v, got %v instead", arg0, want, got)
+		}
+	}
+}
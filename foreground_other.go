@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package pseudoterm
+
+func foreground(fd uintptr) (f Foreground, err error) {
+	return f, ErrUnsupported
+}
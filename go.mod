@@ -0,0 +1,13 @@
+module github.com/henvic/pseudoterm
+
+go 1.21
+
+require (
+	github.com/kr/pty v1.1.4
+	github.com/kylelemons/godebug v1.1.0
+	go.starlark.net v0.0.0-20231121155337-90ade8b19d09
+	go.uber.org/goleak v1.3.0
+	golang.org/x/crypto v0.17.0
+	golang.org/x/sys v0.15.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,84 @@
+package pseudoterm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// inputRequested tells if the foreground process group leader of the pseudo tty
+// is blocked reading from it, looking at the system calls of its threads
+func inputRequested(fd uintptr) (bool, error) {
+	pgrp, err := unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+
+	if err != nil {
+		return false, err
+	}
+
+	ptn, err := unix.IoctlGetInt(int(fd), unix.TIOCGPTN)
+
+	if err != nil {
+		return false, err
+	}
+
+	var tty = fmt.Sprintf("/dev/pts/%d", ptn)
+	tasks, err := filepath.Glob(fmt.Sprintf("/proc/%d/task/*", pgrp))
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, task := range tasks {
+		if readingTTY(task, pgrp, tty) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func readingTTY(task string, pid int, tty string) bool {
+	var b, err = os.ReadFile(filepath.Join(task, "syscall"))
+
+	if err != nil {
+		return false
+	}
+
+	// "running" or "number arg1 ... arg6 sp pc" when blocked on a system call
+	var f = strings.Fields(string(b))
+
+	if len(f) < 2 {
+		return false
+	}
+
+	nr, err := strconv.Atoi(f[0])
+
+	if err != nil {
+		return false
+	}
+
+	arg, err := strconv.ParseUint(f[1], 0, 64)
+
+	if err != nil {
+		return false
+	}
+
+	switch nr {
+	case unix.SYS_READ, unix.SYS_READV:
+		return isTTY(pid, arg, tty)
+	case unix.SYS_PSELECT6:
+		// waiting only for the standard input, as shells using readline do
+		return arg == 1 && isTTY(pid, 0, tty)
+	default:
+		return false
+	}
+}
+
+func isTTY(pid int, fd uint64, tty string) bool {
+	var link, err = os.Readlink(fmt.Sprintf("/proc/%d/fd/%d", pid, fd))
+	return err == nil && (link == tty || link == "/dev/tty")
+}
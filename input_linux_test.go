@@ -0,0 +1,117 @@
+package pseudoterm
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTerminalWaitForInputRequest(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var term = &Terminal{
+		Command:    exec.Command("mocks/mock.sh"),
+		EchoStream: echoStream,
+	}
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, in := range []string{"Henrique", "10"} {
+		if err := term.WaitForInputRequest(ctx); err != nil {
+			t.Fatalf("Expected no error waiting for input request, got %v instead", err)
+		}
+
+		if _, err := term.WriteLine(in); err != nil {
+			t.Fatalf("Expected no error writing, got %v instead", err)
+		}
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	if err := term.WaitForInputRequest(ctx); err == nil {
+		t.Errorf("Expected error waiting for input request after program ended")
+	}
+
+	if !strings.Contains(echoStream.String(), "Your age is 10") {
+		t.Errorf("Unexpected output %q", echoStream.String())
+	}
+}
+
+func TestTerminalWaitForInputRequestTimeout(t *testing.T) {
+	var term = &Terminal{
+		Command: exec.Command("sleep", "1"),
+	}
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	defer term.Stop()
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	if err := term.WaitForInputRequest(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected error to be %v, got %v instead", context.DeadlineExceeded, err)
+	}
+}
+
+func TestTerminalWithInputRequestedStep(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var term = &Terminal{
+		Command:    exec.Command("sh", "-c", "echo Loading; sleep 0.2; read x; echo \"Got $x\""),
+		EchoStream: echoStream,
+	}
+
+	var story = &QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(
+		Step{
+			Read:           "Loading",
+			InputRequested: true,
+			Write:          "hi",
+		},
+		Step{
+			Read:      "Got hi",
+			SkipWrite: true,
+		})
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success: %+v", story.Sequence)
+	}
+}
+
+func TestStoryInputRequestedStep(t *testing.T) {
+	var story = &QueueStory{}
+
+	story.Add(Step{
+		InputRequested: true,
+		Write:          "yes",
+	})
+
+	if _, err := story.HandleLine("Continue?"); err != SkipWrite {
+		t.Errorf("Expected step to not match lines, got %v instead", err)
+	}
+
+	if _, err := story.HandleState(State{}); err != SkipWrite {
+		t.Errorf("Expected step to not match before input is requested, got %v instead", err)
+	}
+
+	if in, err := story.HandleState(State{InputRequested: true}); in != "yes" || err != nil {
+		t.Errorf("Expected step to match, got (%v, %v) instead", in, err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package pseudoterm
+
+func inputRequested(fd uintptr) (bool, error) {
+	return false, ErrUnsupported
+}
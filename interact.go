@@ -0,0 +1,224 @@
+package pseudoterm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultEscape is the key returning control from an interaction: Ctrl-]
+var DefaultEscape = "\x1d"
+
+// interactionTail is how much of the output is kept to match Until
+const interactionTail = 4096
+
+// Interaction of the user with the program through the terminal of the current process.
+// Stdin and Stdout default to os.Stdin and os.Stdout, and Escape to DefaultEscape.
+// Until ends the interaction when the output of the program matches it.
+type Interaction struct {
+	Stdin  *os.File
+	Stdout io.Writer
+	Escape string
+	Until  *regexp.Regexp
+}
+
+// Interact hands the program to the user, like expect's interact.
+// See InteractWith.
+func (t *Terminal) Interact(ctx context.Context) error {
+	return t.InteractWith(ctx, Interaction{})
+}
+
+// InteractWith puts Stdin into raw mode, forwarding the keys pressed to the
+// program and its output to Stdout, and propagating window size changes.
+// It returns when the user presses Escape, the output matches Until,
+// the program ends or the context is done.
+// The output is still handled by the story afterwards:
+// set the Terminal SkipBuffer if you don't watch stories on it.
+func (t *Terminal) InteractWith(ctx context.Context, i Interaction) error {
+	if t.transport == nil {
+		return errNotStarted
+	}
+
+	var stdin, stdout, escape = i.Stdin, i.Stdout, []byte(i.Escape)
+
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	if len(escape) == 0 {
+		escape = []byte(DefaultEscape)
+	}
+
+	var fd = int(stdin.Fd())
+
+	// stdin might not be a terminal, when it can't be put into raw mode or resized
+	if restore, err := makeRaw(fd); err == nil {
+		defer restore()
+	}
+
+	t.resizeFrom(fd)
+
+	var winch = make(chan os.Signal, 1)
+	notifyResize(winch)
+	defer signal.Stop(winch)
+
+	var out = &interactionWriter{
+		w:       stdout,
+		until:   i.Until,
+		matched: make(chan struct{}),
+	}
+
+	t.setTap(out)
+	defer t.setTap(nil)
+
+	var in, err = t.readInteraction(fd)
+
+	if err != nil {
+		return err
+	}
+
+	defer in.stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.end:
+			return nil
+		case <-out.matched:
+			return nil
+		case <-winch:
+			t.resizeFrom(fd)
+		case err := <-in.err:
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		case b := <-in.keys:
+			var end = bytes.Index(b, escape)
+
+			if end != -1 {
+				b = b[:end]
+			}
+
+			if _, err := t.Write(b); err != nil {
+				return err
+			}
+
+			if end != -1 {
+				return nil
+			}
+		}
+	}
+}
+
+func (t *Terminal) setTap(w io.Writer) {
+	t.tapMu.Lock()
+	defer t.tapMu.Unlock()
+	t.tap = w
+}
+
+// resizeFrom sets the size of the terminal to the size of the terminal fd
+func (t *Terminal) resizeFrom(fd int) {
+	if rows, cols, err := getSize(fd); err == nil && rows != 0 && cols != 0 {
+		_ = t.SetSize(rows, cols)
+	}
+}
+
+// interactionReader reads the keys pressed by the user
+type interactionReader struct {
+	f       *os.File
+	restore func()
+	keys    chan []byte
+	err     chan error
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func (t *Terminal) readInteraction(fd int) (*interactionReader, error) {
+	var f, restore, err = nonblockingDup(fd)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var r = &interactionReader{
+		f:       f,
+		restore: restore,
+		keys:    make(chan []byte),
+		err:     make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+
+	r.wg.Add(1)
+	go r.read()
+	return r, nil
+}
+
+func (r *interactionReader) read() {
+	defer r.wg.Done()
+	var buf = make([]byte, 1024)
+
+	for {
+		var n, err = r.f.Read(buf)
+
+		if n != 0 {
+			select {
+			case r.keys <- append([]byte(nil), buf[:n]...):
+			case <-r.done:
+				return
+			}
+		}
+
+		if err != nil {
+			r.err <- err
+			return
+		}
+	}
+}
+
+// stop reading, so the keys pressed afterwards are left for the current process
+func (r *interactionReader) stop() {
+	close(r.done)
+	_ = r.f.SetReadDeadline(time.Now())
+	r.wg.Wait()
+	_ = r.f.Close()
+	r.restore()
+}
+
+// interactionWriter writes the output to the user, looking for the Until pattern
+type interactionWriter struct {
+	w       io.Writer
+	until   *regexp.Regexp
+	tail    []byte
+	matched chan struct{}
+	once    sync.Once
+}
+
+func (i *interactionWriter) Write(p []byte) (n int, err error) {
+	if i.until != nil {
+		i.tail = append(i.tail, p...)
+
+		if len(i.tail) > interactionTail {
+			i.tail = i.tail[len(i.tail)-interactionTail:]
+		}
+
+		if i.until.Match(i.tail) {
+			i.once.Do(func() {
+				close(i.matched)
+			})
+		}
+	}
+
+	return i.w.Write(p)
+}
@@ -0,0 +1,93 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package pseudoterm
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kr/pty"
+)
+
+func TestTerminalInteract(t *testing.T) {
+	// the terminal of the user
+	var master, slave, err = pty.Open()
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	defer master.Close()
+	defer slave.Close()
+
+	if err := pty.Setsize(master, &pty.Winsize{Rows: 30, Cols: 100}); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	var echoStream = &syncBuffer{}
+	var term = &Terminal{
+		Command:    exec.Command("sh", "-c", `read x; echo "got $x"; stty size; read y; echo "bye $y"`),
+		EchoStream: echoStream,
+	}
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	defer term.Stop()
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var stdout = &syncBuffer{}
+
+	if _, err := master.WriteString("hello\r"); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	err = term.InteractWith(ctx, Interaction{
+		Stdin:  slave,
+		Stdout: stdout,
+		Until:  regexp.MustCompile(`\d+ \d+`),
+	})
+
+	if err != nil {
+		t.Errorf("Expected no error interacting, got %v instead", err)
+	}
+
+	if !strings.Contains(stdout.String(), "got hello") || !strings.Contains(stdout.String(), "30 100") {
+		t.Errorf("Expected output to be forwarded with the size of the user terminal, got %q instead", stdout.String())
+	}
+
+	if m, err := getMode(slave.Fd()); err != nil || !m.Echo || !m.Canonical {
+		t.Errorf("Expected user terminal mode to be restored, got (%+v, %v) instead", m, err)
+	}
+
+	if _, err := master.WriteString("world\r" + DefaultEscape + "ignored"); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if err := term.InteractWith(ctx, Interaction{Stdin: slave, Stdout: stdout}); err != nil {
+		t.Errorf("Expected no error interacting, got %v instead", err)
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	if out := echoStream.String(); !strings.Contains(out, "bye world") || strings.Contains(out, "ignored") {
+		t.Errorf("Expected input before the escape key to be forwarded, got %q instead", out)
+	}
+}
+
+func TestTerminalInteractNotStarted(t *testing.T) {
+	var term = &Terminal{}
+
+	if err := term.Interact(context.Background()); err != errNotStarted {
+		t.Errorf("Expected error to be %v, got %v instead", errNotStarted, err)
+	}
+}
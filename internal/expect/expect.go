@@ -0,0 +1,143 @@
+// Package expect buffers the output of a program to wait for patterns in it.
+package expect
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+)
+
+// ErrClosed is returned when the output is done before a pattern is found
+var ErrClosed = errors.New("Output closed")
+
+// Buffer of output, written to by a terminal (usually as its EchoStream)
+type Buffer struct {
+	mu      sync.Mutex
+	b       []byte
+	changed chan struct{}
+}
+
+// Write to the buffer, waking up whoever is waiting for a pattern
+func (b *Buffer) Write(p []byte) (n int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.b = append(b.b, p...)
+
+	if b.changed != nil {
+		close(b.changed)
+		b.changed = nil
+	}
+
+	return len(p), nil
+}
+
+// Expect waits for re to match the buffered output and consumes it until the
+// end of the match, returning what comes before it and the submatches.
+// If done is closed without a match, it returns ErrClosed.
+func (b *Buffer) Expect(ctx context.Context, re *regexp.Regexp, done <-chan struct{}) (before string, match []string, err error) {
+	err = b.wait(ctx, done, func() bool {
+		var loc = re.FindSubmatchIndex(b.b)
+
+		if loc != nil {
+			before, match = b.consume(loc)
+		}
+
+		return loc != nil
+	})
+
+	return before, match, err
+}
+
+// ExpectAny waits for any of res to match the buffered output and consumes it
+// until the end of the earliest match, returning the index of the pattern
+// matched and the submatches. If done is closed without a match, it returns ErrClosed.
+func (b *Buffer) ExpectAny(ctx context.Context, res []*regexp.Regexp, done <-chan struct{}) (index int, match []string, err error) {
+	index = -1
+
+	err = b.wait(ctx, done, func() bool {
+		var first []int
+
+		for c, re := range res {
+			var loc = re.FindSubmatchIndex(b.b)
+
+			if loc != nil && (first == nil || loc[0] < first[0]) {
+				index, first = c, loc
+			}
+		}
+
+		if first != nil {
+			_, match = b.consume(first)
+		}
+
+		return first != nil
+	})
+
+	return index, match, err
+}
+
+// Wait until fn returns true for the buffered output, without consuming it.
+// If done is closed first, it returns ErrClosed.
+func (b *Buffer) Wait(ctx context.Context, done <-chan struct{}, fn func(out string) bool) error {
+	return b.wait(ctx, done, func() bool {
+		return fn(string(b.b))
+	})
+}
+
+// wait until fn, called with the lock held, returns true
+func (b *Buffer) wait(ctx context.Context, done <-chan struct{}, fn func() bool) (err error) {
+	for {
+		b.mu.Lock()
+
+		if fn() {
+			b.mu.Unlock()
+			return nil
+		}
+
+		if err != nil {
+			b.mu.Unlock()
+			return err
+		}
+
+		if b.changed == nil {
+			b.changed = make(chan struct{})
+		}
+
+		var changed = b.changed
+		b.mu.Unlock()
+
+		// check one last time when giving up, as the output might have what is expected
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-changed:
+		case <-done:
+			err = ErrClosed
+		}
+	}
+}
+
+// Take the buffered output, discarding it
+func (b *Buffer) Take() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var s = string(b.b)
+	b.b = nil
+	return s
+}
+
+func (b *Buffer) consume(loc []int) (before string, match []string) {
+	before = string(b.b[:loc[0]])
+
+	for c := 0; c < len(loc); c += 2 {
+		if loc[c] == -1 {
+			match = append(match, "")
+			continue
+		}
+
+		match = append(match, string(b.b[loc[c]:loc[c+1]]))
+	}
+
+	b.b = append([]byte(nil), b.b[loc[1]:]...)
+	return before, match
+}
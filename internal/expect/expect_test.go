@@ -0,0 +1,115 @@
+package expect
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestExpect(t *testing.T) {
+	var b = &Buffer{}
+	var re = regexp.MustCompile(`<(\d+)>`)
+
+	go func() {
+		for _, s := range []string{"hello ", "world <", "42> rest"} {
+			time.Sleep(10 * time.Millisecond)
+			_, _ = b.Write([]byte(s))
+		}
+	}()
+
+	var before, match, err = b.Expect(context.Background(), re, nil)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if before != "hello world " || len(match) != 2 || match[1] != "42" {
+		t.Errorf("Unexpected match (%q, %q)", before, match)
+	}
+
+	if rest := b.Take(); rest != " rest" {
+		t.Errorf("Expected rest of buffer to be %q, got %q instead", " rest", rest)
+	}
+}
+
+func TestExpectDone(t *testing.T) {
+	var b = &Buffer{}
+	var done = make(chan struct{})
+	var re = regexp.MustCompile(`end`)
+
+	_, _ = b.Write([]byte("no match"))
+	close(done)
+
+	if _, _, err := b.Expect(context.Background(), re, done); err != ErrClosed {
+		t.Errorf("Expected error to be %v, got %v instead", ErrClosed, err)
+	}
+
+	_, _ = b.Write([]byte(" at the end"))
+
+	if before, _, err := b.Expect(context.Background(), re, done); before != "no match at the " || err != nil {
+		t.Errorf("Expected match of the last output, got (%q, %v) instead", before, err)
+	}
+}
+
+func TestExpectAny(t *testing.T) {
+	var b = &Buffer{}
+	_, _ = b.Write([]byte("Try again\nWelcome, henvic\n"))
+
+	var res = []*regexp.Regexp{
+		regexp.MustCompile(`Welcome, (\w+)`),
+		regexp.MustCompile(`Try again`),
+	}
+
+	var index, match, err = b.ExpectAny(context.Background(), res, nil)
+
+	if index != 1 || len(match) != 1 || err != nil {
+		t.Errorf("Expected earliest match to be of pattern 1, got (%v, %q, %v) instead", index, match, err)
+	}
+
+	index, match, err = b.ExpectAny(context.Background(), res, nil)
+
+	if index != 0 || len(match) != 2 || match[1] != "henvic" || err != nil {
+		t.Errorf("Expected match of pattern 0, got (%v, %q, %v) instead", index, match, err)
+	}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	if index, _, err = b.ExpectAny(ctx, res, nil); index != -1 || err != context.Canceled {
+		t.Errorf("Expected error to be %v, got (%v, %v) instead", context.Canceled, index, err)
+	}
+}
+
+func TestExpectContext(t *testing.T) {
+	var b = &Buffer{}
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, _, err := b.Expect(ctx, regexp.MustCompile(`x`), nil); err != context.DeadlineExceeded {
+		t.Errorf("Expected error to be %v, got %v instead", context.DeadlineExceeded, err)
+	}
+}
+
+func TestWait(t *testing.T) {
+	var b = &Buffer{}
+
+	go func() {
+		for _, s := range []string{"a", "b", "c"} {
+			time.Sleep(10 * time.Millisecond)
+			_, _ = b.Write([]byte(s))
+		}
+	}()
+
+	var err = b.Wait(context.Background(), nil, func(out string) bool {
+		return len(out) == 3
+	})
+
+	if err != nil {
+		t.Errorf("Expected no error, got %v instead", err)
+	}
+
+	if out := b.Take(); out != "abc" {
+		t.Errorf("Expected output to not be consumed, got %q instead", out)
+	}
+}
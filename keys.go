@@ -0,0 +1,46 @@
+package pseudoterm
+
+import "fmt"
+
+// Keys maps key names to the input a terminal sends when they are pressed
+var Keys = map[string]string{
+	"enter":     "\r",
+	"tab":       "\t",
+	"space":     " ",
+	"backspace": "\x7f",
+	"esc":       "\x1b",
+	"up":        "\x1b[A",
+	"down":      "\x1b[B",
+	"right":     "\x1b[C",
+	"left":      "\x1b[D",
+	"home":      "\x1b[H",
+	"end":       "\x1b[F",
+	"delete":    "\x1b[3~",
+	"pageup":    "\x1b[5~",
+	"pagedown":  "\x1b[6~",
+	"ctrl-a":    "\x01",
+	"ctrl-c":    "\x03",
+	"ctrl-d":    "\x04",
+	"ctrl-e":    "\x05",
+	"ctrl-l":    "\x0c",
+	"ctrl-u":    "\x15",
+	"ctrl-w":    "\x17",
+	"ctrl-z":    "\x1a",
+}
+
+// KeySequence returns the input for pressing the given keys in order
+func KeySequence(names ...string) (string, error) {
+	var s string
+
+	for _, n := range names {
+		k, ok := Keys[n]
+
+		if !ok {
+			return "", fmt.Errorf("Unknown key %q", n)
+		}
+
+		s += k
+	}
+
+	return s, nil
+}
@@ -0,0 +1,45 @@
+package pseudoterm
+
+import "testing"
+
+func TestKeySequence(t *testing.T) {
+	var s, err = KeySequence("down", "down", "enter")
+
+	if err != nil {
+		t.Errorf("Expected no error, got %v instead", err)
+	}
+
+	if want := "\x1b[B\x1b[B\r"; s != want {
+		t.Errorf("Expected sequence to be %q, got %q instead", want, s)
+	}
+
+	if _, err := KeySequence("up", "hyper"); err == nil || err.Error() != `Unknown key "hyper"` {
+		t.Errorf("Expected unknown key error, got %v instead", err)
+	}
+}
+
+func TestStoryHandleLineWithKeys(t *testing.T) {
+	var story = &QueueStory{}
+
+	story.Add(Step{
+		Read:  "Name:",
+		Write: "Henrique",
+		Keys:  []string{"tab", "enter"},
+	},
+		Step{
+			Read: "Region:",
+			Keys: []string{"hyper"},
+		})
+
+	if _, err := story.Setup(); err != nil {
+		t.Errorf("Expected story setup to be fine, got %v error instead", err)
+	}
+
+	if in, err := story.HandleLine("Name:"); in != "Henrique\t\r" || err != WriteRaw {
+		t.Errorf("Expected values doesn't match: (%q, %v)", in, err)
+	}
+
+	if _, err := story.HandleLine("Region:"); err == nil || err.Error() != `Unknown key "hyper"` {
+		t.Errorf("Expected unknown key error, got %v instead", err)
+	}
+}
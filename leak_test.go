@@ -0,0 +1,263 @@
+//go:build !windows
+// +build !windows
+
+package pseudoterm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func TestWatchCancelDoesNotLeak(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	var term = &Terminal{
+		Command: exec.Command("mocks/mock.sh"),
+	}
+
+	var story = &QueueStory{
+		Timeout: 100 * time.Millisecond,
+	}
+
+	var handling int32
+
+	story.Add(Step{
+		// handling lines outlives the story timeout
+		ReadFunc: func(in string) bool {
+			atomic.AddInt32(&handling, 1)
+			time.Sleep(200 * time.Millisecond)
+			atomic.AddInt32(&handling, -1)
+			return false
+		},
+		Write: "x",
+	})
+
+	if err := term.Run(story); err == nil {
+		t.Errorf("Expected story to time out")
+	}
+
+	if atomic.LoadInt32(&handling) != 0 {
+		t.Errorf("Expected Watch to wait for the line being handled")
+	}
+}
+
+func TestWatchSecondStory(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	var term = &Terminal{
+		Command: exec.Command("mocks/mock.sh"),
+	}
+
+	var first = &QueueStory{
+		Timeout:         5 * time.Second,
+		ReturnOnSuccess: true,
+	}
+
+	first.Add(Step{
+		Read:  "Your name:",
+		Write: "Henrique",
+	})
+
+	var second = &QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	second.Add(
+		Step{
+			Read:  "Your age:",
+			Write: "10",
+		},
+		Step{
+			Read:      "Bye!",
+			SkipWrite: true,
+		})
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if err := term.Watch(first); err != nil || !first.Success() {
+		t.Errorf("Expected first story to succeed, got %v instead", err)
+	}
+
+	if term.ended() {
+		t.Errorf("Expected program to be running after the first story")
+	}
+
+	if err := term.Watch(second); err != nil || !second.Success() {
+		t.Errorf("Expected second story to succeed, got %v instead", err)
+	}
+
+	if err := term.Stop(); err != nil {
+		t.Errorf("Expected no error stopping, got %v instead", err)
+	}
+}
+
+func TestWatchSecondStoryWithoutDelay(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	var term = &Terminal{
+		Transport: &Program{
+			Func: func(stdin io.Reader, stdout io.Writer) int {
+				var in = bufio.NewReader(stdin)
+				fmt.Fprintln(stdout, "a")
+				_, _ = in.ReadString('\n')
+				fmt.Fprint(stdout, "b\nc\n")
+				_, _ = io.Copy(io.Discard, in)
+				return 0
+			},
+		},
+	}
+
+	var first = &QueueStory{
+		Timeout:         5 * time.Second,
+		ReturnOnSuccess: true,
+	}
+
+	first.Add(Step{
+		Read:  "a",
+		Write: "go",
+	})
+
+	var second = &QueueStory{
+		Timeout:         5 * time.Second,
+		ReturnOnSuccess: true,
+	}
+
+	second.Add(
+		Step{
+			Read:      "b",
+			SkipWrite: true,
+		},
+		Step{
+			Read:      "c",
+			SkipWrite: true,
+		})
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if err := term.Watch(first); err != nil || !first.Success() {
+		t.Errorf("Expected first story to succeed, got %v instead", err)
+	}
+
+	// the output of the second story is already there
+	time.Sleep(50 * time.Millisecond)
+
+	if err := term.Watch(second); err != nil || !second.Success() {
+		t.Errorf("Expected second story to succeed, got %v instead", err)
+	}
+
+	if err := term.Stop(); err != nil {
+		t.Errorf("Expected no error stopping, got %v instead", err)
+	}
+}
+
+func TestStopKillsProgram(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	var defaultGracePeriod = StopGracePeriod
+	StopGracePeriod = 100 * time.Millisecond
+
+	defer func() {
+		StopGracePeriod = defaultGracePeriod
+	}()
+
+	var term = &Terminal{
+		Command: exec.Command("sh", "-c", `trap "" HUP; echo ready; exec sleep 10`),
+	}
+
+	var story = &QueueStory{
+		Timeout:         5 * time.Second,
+		ReturnOnSuccess: true,
+	}
+
+	story.Add(Step{
+		Read:      "ready",
+		SkipWrite: true,
+	})
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if err := term.Watch(story); err != nil {
+		t.Errorf("Expected no error watching, got %v instead", err)
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var stopped = make(chan error, 1)
+
+	go func() {
+		stopped <- term.Stop()
+	}()
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("Expected no error stopping, got %v instead", err)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Expected program to be killed")
+	}
+
+	if !term.ended() {
+		t.Errorf("Expected program to have ended")
+	}
+}
+
+func TestStopClosesTransport(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	var run = func() {
+		var term = &Terminal{
+			Command:        exec.Command("echo", "hello"),
+			SeparateStderr: true,
+		}
+
+		if err := term.Start(); err != nil {
+			t.Fatalf("Expected no error, got %v instead", err)
+		}
+
+		term.Wait()
+
+		if err := term.Stop(); err != nil {
+			t.Errorf("Expected no error stopping, got %v instead", err)
+		}
+	}
+
+	// the first run might open files kept by the runtime, like the netpoller
+	run()
+
+	var want = openFiles(t)
+
+	for c := 0; c < 3; c++ {
+		run()
+	}
+
+	if got := openFiles(t); got != want {
+		t.Errorf("Expected %d open files after stopping, got %d instead", want, got)
+	}
+}
+
+func openFiles(t *testing.T) int {
+	var files, err = os.ReadDir("/dev/fd")
+
+	if err != nil {
+		t.Fatalf("Expected no error listing open files, got %v instead", err)
+	}
+
+	return len(files)
+}
@@ -0,0 +1,193 @@
+package pseudoterm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secret values
+var Redacted = "[redacted]"
+
+// discard logger, used when no Logger is set
+var discard = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
+
+func logger(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return discard
+	}
+
+	return l
+}
+
+// Secrets are values, such as passwords, redacted from logs.
+// The zero value is ready to use.
+type Secrets struct {
+	mu     sync.RWMutex
+	values []string
+}
+
+// Add secret values
+func (s *Secrets) Add(values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range values {
+		if v != "" {
+			s.values = append(s.values, v)
+		}
+	}
+
+	// the longest values are redacted first, as they might contain others
+	sort.SliceStable(s.values, func(i, j int) bool {
+		return len(s.values[i]) > len(s.values[j])
+	})
+}
+
+// Redact the secret values of a string
+func (s *Secrets) Redact(str string) string {
+	if s == nil {
+		return str
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, v := range s.values {
+		str = strings.Replace(str, v, Redacted, -1)
+	}
+
+	return str
+}
+
+// Handler wraps a slog.Handler, redacting the secret values
+// of the messages and attributes
+func (s *Secrets) Handler(h slog.Handler) slog.Handler {
+	return &redactHandler{h: h, s: s}
+}
+
+type redactHandler struct {
+	h slog.Handler
+	s *Secrets
+}
+
+func (r *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return r.h.Enabled(ctx, level)
+}
+
+func (r *redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	var nr = slog.NewRecord(record.Time, record.Level, r.s.Redact(record.Message), record.PC)
+
+	record.Attrs(func(a slog.Attr) bool {
+		nr.AddAttrs(r.attr(a))
+		return true
+	})
+
+	return r.h.Handle(ctx, nr)
+}
+
+func (r *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var ra = make([]slog.Attr, len(attrs))
+
+	for c, a := range attrs {
+		ra[c] = r.attr(a)
+	}
+
+	return &redactHandler{h: r.h.WithAttrs(ra), s: r.s}
+}
+
+func (r *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{h: r.h.WithGroup(name), s: r.s}
+}
+
+func (r *redactHandler) attr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.s.Redact(a.Value.String()))
+	case slog.KindGroup:
+		var group = a.Value.Group()
+		var ra = make([]slog.Attr, len(group))
+
+		for c, ga := range group {
+			ra[c] = r.attr(ga)
+		}
+
+		a.Value = slog.GroupValue(ra...)
+	case slog.KindAny:
+		// such as errors
+		var s = fmt.Sprint(a.Value.Any())
+
+		if rs := r.s.Redact(s); rs != s {
+			a.Value = slog.StringValue(rs)
+		}
+	}
+
+	return a
+}
+
+// scrubber writes to w with the secrets redacted, holding back the end of the
+// output while it might be the beginning of a secret
+type scrubber struct {
+	w       io.Writer
+	secrets *Secrets
+	pending string
+}
+
+func newScrubber(w io.Writer, secrets *Secrets) *scrubber {
+	return &scrubber{w: w, secrets: secrets}
+}
+
+func (s *scrubber) Write(p []byte) (n int, err error) {
+	var out = s.secrets.Redact(s.pending + string(p))
+	var hold = s.secrets.partial(out)
+	out, s.pending = out[:len(out)-hold], out[len(out)-hold:]
+
+	if len(out) != 0 {
+		if _, err = io.WriteString(s.w, out); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush the output held back
+func (s *scrubber) Flush() error {
+	if s.pending == "" {
+		return nil
+	}
+
+	var _, err = io.WriteString(s.w, s.pending)
+	s.pending = ""
+	return err
+}
+
+// partial is the length of the longest end of str that is the beginning of a secret
+func (s *Secrets) partial(str string) (n int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, v := range s.values {
+		for k := len(v) - 1; k > n; k-- {
+			if strings.HasSuffix(str, v[:k]) {
+				n = k
+				break
+			}
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,181 @@
+//go:build !windows
+// +build !windows
+
+package pseudoterm
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecretsRedact(t *testing.T) {
+	var s = &Secrets{}
+	s.Add("hunter", "", "hunter2")
+
+	if got, want := s.Redact("password hunter2, not hunter"), "password [redacted], not [redacted]"; got != want {
+		t.Errorf("Expected %q, got %q instead", want, got)
+	}
+
+	var nilSecrets *Secrets
+
+	if got := nilSecrets.Redact("hunter2"); got != "hunter2" {
+		t.Errorf("Expected nil secrets to not redact, got %q instead", got)
+	}
+}
+
+func TestScrubber(t *testing.T) {
+	var s = &Secrets{}
+	s.Add("hunter2")
+
+	var b = &bytes.Buffer{}
+	var w = newScrubber(b, s)
+
+	for _, p := range []string{"password: hun", "ter", "2\nhunt", "er\nhun"} {
+		if _, err := w.Write([]byte(p)); err != nil {
+			t.Fatalf("Expected no error, got %v instead", err)
+		}
+	}
+
+	if want := "password: [redacted]\nhunter\n"; b.String() != want {
+		t.Errorf("Expected %q before flushing, got %q instead", want, b.String())
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if want := "password: [redacted]\nhunter\nhun"; b.String() != want {
+		t.Errorf("Expected %q, got %q instead", want, b.String())
+	}
+}
+
+func TestExecutionErrorRedacted(t *testing.T) {
+	var s = &Secrets{}
+	s.Add("hunter2")
+
+	var err = ExecutionError{
+		ExitError: errors.New("invalid token hunter2"),
+		secrets:   s,
+	}
+
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("Expected secret to be redacted, got %v instead", err)
+	}
+}
+
+func TestSecretsHandler(t *testing.T) {
+	var s = &Secrets{}
+	var b = &bytes.Buffer{}
+	var l = slog.New(s.Handler(slog.NewTextHandler(b, nil)))
+	s.Add("hunter2")
+
+	l.With("user", "henvic", "token", "hunter2").WithGroup("login").Info("Typed hunter2",
+		"input", "hunter2\n",
+		"error", errors.New("wrong password hunter2"),
+		slog.Group("step", "write", "hunter2"))
+
+	if strings.Contains(b.String(), "hunter2") || !strings.Contains(b.String(), "user=henvic") {
+		t.Errorf("Expected secret to be redacted, got %q instead", b.String())
+	}
+
+	if n := strings.Count(b.String(), Redacted); n != 5 {
+		t.Errorf("Expected 5 redacted values, got %d instead: %q", n, b.String())
+	}
+}
+
+func TestTerminalLogger(t *testing.T) {
+	var secrets = &Secrets{}
+	secrets.Add("Henrique")
+
+	var b = &syncBuffer{}
+	var l = slog.New(secrets.Handler(slog.NewTextHandler(b, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	var term = &Terminal{
+		Command: exec.Command("mocks/mock.sh"),
+		Logger:  l,
+	}
+
+	var story = &QueueStory{
+		Timeout: 5 * time.Second,
+		Logger:  l,
+	}
+
+	story.Add(
+		Step{
+			Read:  "Your name:",
+			Write: "Henrique",
+		},
+		Step{
+			ReadRegex: regexp.MustCompile("Your age:"),
+			Write:     "10",
+		})
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	term.Wait()
+
+	var out = b.String()
+
+	for _, want := range []string{
+		`msg="Program started" program=mocks/mock.sh`,
+		`msg="Step matched" step=0 matcher=read line="Your name: "`,
+		`msg="Wrote input" input="[redacted]\n" bytes=9`,
+		`msg="Step matched" step=1 matcher=regex`,
+		`msg="Program exited" exit_code=0 elapsed=`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected log to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	if strings.Contains(out, "Henrique") {
+		t.Errorf("Expected secret to be redacted from the log, got:\n%s", out)
+	}
+}
+
+func TestTerminalSecretStep(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var term = &Terminal{
+		Command:    exec.Command("mocks/mock-password.sh"),
+		EchoStream: echoStream,
+	}
+
+	var story = &QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(
+		Step{
+			Read:   "Your name:",
+			Write:  "Henrique",
+			Secret: true,
+		},
+		Step{
+			NoEcho: true,
+			Write:  "hunter2",
+			Secret: true,
+		})
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	if want := "Hello [redacted], your code has 7 characters"; !strings.Contains(echoStream.String(), want) {
+		t.Errorf("Expected echo to contain %q, got %q instead", want, echoStream.String())
+	}
+
+	if strings.Contains(echoStream.String(), "Henrique") {
+		t.Errorf("Expected secret to be scrubbed from the echo, got %q instead", echoStream.String())
+	}
+}
@@ -0,0 +1,332 @@
+/*
+Package menu operates arrow-key menus rendered by prompt libraries, like
+
+	? Choose a region:
+	  us-east-1
+	> us-west-2
+	  eu-west-1
+
+The Driver reads the last rendering of a menu from the output of the terminal,
+without ANSI escape sequences, presses the keys to reach the wanted options
+and verifies the choice was accepted.
+
+	var d = &menu.Driver{Terminal: term}
+	term.EchoStream = d
+	term.SkipBuffer = true
+
+	if err := term.Start(); err != nil {
+		return err
+	}
+
+	if err := d.Select(ctx, "Choose a region", "eu-west-1"); err != nil {
+		return err
+	}
+*/
+package menu
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/henvic/pseudoterm"
+	"github.com/henvic/pseudoterm/internal/expect"
+)
+
+var (
+	// DefaultCursor matches the row under the cursor
+	DefaultCursor = regexp.MustCompile(`^\s*[>❯»▸→]`)
+
+	// DefaultChecked matches the checked rows of multi-select menus
+	DefaultChecked = regexp.MustCompile(`\[[xX✓✔*]\]|[◉●☑✔]`)
+
+	// DefaultUnchecked matches the unchecked rows of multi-select menus
+	DefaultUnchecked = regexp.MustCompile(`\[ \]|[◯○☐]`)
+)
+
+var ansi = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07]*\x07|[@-Z\\-_])`)
+
+// Driver of the menus of a Terminal.
+// It must receive the output of the terminal: use it as the EchoStream
+// (or a part of it, with io.MultiWriter) before starting the terminal.
+// Set the Terminal SkipBuffer, unless you watch stories on it too.
+// Cursor, Checked and Unchecked default to DefaultCursor, DefaultChecked
+// and DefaultUnchecked.
+type Driver struct {
+	Terminal  *pseudoterm.Terminal
+	Cursor    *regexp.Regexp
+	Checked   *regexp.Regexp
+	Unchecked *regexp.Regexp
+
+	out expect.Buffer
+}
+
+// row of a menu
+type row struct {
+	option  string
+	cursor  bool
+	checked bool
+}
+
+// Write output of the terminal
+func (d *Driver) Write(p []byte) (n int, err error) {
+	return d.out.Write(p)
+}
+
+// Select the option of the menu with the given question
+func (d *Driver) Select(ctx context.Context, question, option string) error {
+	var rows, err = d.menu(ctx, question, func(rows []row) bool {
+		return index(rows, option) != -1
+	})
+
+	if err != nil {
+		return fmt.Errorf("Option %q not found on menu %q: %v", option, question, err)
+	}
+
+	if err = d.move(ctx, question, rows, index(rows, option), nil); err != nil {
+		return err
+	}
+
+	return d.accept(ctx, question, []string{option})
+}
+
+// MultiSelect checks the given options of the menu with the given question,
+// unchecking the others
+func (d *Driver) MultiSelect(ctx context.Context, question string, options []string) error {
+	var rows, err = d.menu(ctx, question, func(rows []row) bool {
+		for _, o := range options {
+			if index(rows, o) == -1 {
+				return false
+			}
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return fmt.Errorf("Options %q not found on menu %q: %v", options, question, err)
+	}
+
+	for i, r := range rows {
+		var wanted = contains(options, r.option)
+
+		if r.checked == wanted {
+			continue
+		}
+
+		var toggled = func(rows []row) bool {
+			return len(rows) > i && rows[i].checked == wanted
+		}
+
+		if err = d.move(ctx, question, rows, i, toggled); err != nil {
+			return err
+		}
+
+		if rows, err = d.menu(ctx, question, toggled); err != nil {
+			return err
+		}
+	}
+
+	return d.accept(ctx, question, options)
+}
+
+// move the cursor to the row i, pressing space to toggle it if toggled is set,
+// and verifies the menu is rendered with the cursor on it
+func (d *Driver) move(ctx context.Context, question string, rows []row, i int, toggled func(rows []row) bool) error {
+	var keys = strings.Repeat(pseudoterm.Keys["down"], max(i-cursor(rows), 0)) +
+		strings.Repeat(pseudoterm.Keys["up"], max(cursor(rows)-i, 0))
+
+	if toggled != nil {
+		keys += pseudoterm.Keys["space"]
+	}
+
+	if keys == "" {
+		return nil
+	}
+
+	if _, err := d.Terminal.WriteString(keys); err != nil {
+		return err
+	}
+
+	var _, err = d.menu(ctx, question, func(rows []row) bool {
+		return cursor(rows) == i && (toggled == nil || toggled(rows))
+	})
+
+	if err != nil {
+		return fmt.Errorf("Cursor didn't reach %q on menu %q: %v", rows[i].option, question, err)
+	}
+
+	return nil
+}
+
+// accept the choice with enter, verifying the options are printed afterwards
+func (d *Driver) accept(ctx context.Context, question string, options []string) error {
+	// the current rendering is discarded, so only the output after enter is verified
+	_ = d.out.Take()
+
+	if _, err := d.Terminal.WriteString(pseudoterm.Keys["enter"]); err != nil {
+		return err
+	}
+
+	var err = d.out.Wait(ctx, d.Terminal.OutputDone(), func(out string) bool {
+		for _, l := range lines(out) {
+			if l != "" && !d.cursor().MatchString(l) && containsAll(l, options) {
+				return true
+			}
+		}
+
+		return false
+	})
+
+	if err != nil {
+		return fmt.Errorf("Choice %q wasn't accepted on menu %q: %v", options, question, err)
+	}
+
+	return nil
+}
+
+// menu waits for the last rendering of the menu to satisfy fn, returning it
+func (d *Driver) menu(ctx context.Context, question string, fn func(rows []row) bool) (rows []row, err error) {
+	err = d.out.Wait(ctx, d.Terminal.OutputDone(), func(out string) bool {
+		rows = d.parse(out, question)
+		return cursor(rows) != -1 && fn(rows)
+	})
+
+	return rows, err
+}
+
+// parse the rows of the last rendering of the menu with the question
+func (d *Driver) parse(out, question string) (rows []row) {
+	var ls = lines(out)
+	var start = -1
+
+	for c, l := range ls {
+		if strings.Contains(l, question) {
+			start = c + 1
+		}
+	}
+
+	// the last line might be incomplete
+	if start == -1 || start >= len(ls)-1 {
+		return nil
+	}
+
+	for _, l := range ls[start : len(ls)-1] {
+		if strings.TrimSpace(l) == "" {
+			break
+		}
+
+		rows = append(rows, d.parseRow(l))
+	}
+
+	// redrawing the options without the question renders them again after the previous ones
+	for c := len(rows) - 1; c > 0; c-- {
+		if rows[c].option == rows[0].option {
+			return rows[c:]
+		}
+	}
+
+	return rows
+}
+
+func (d *Driver) parseRow(l string) (r row) {
+	var cursor, checked, unchecked = d.cursor(), d.checked(), d.unchecked()
+
+	if loc := cursor.FindStringIndex(l); loc != nil {
+		r.cursor = true
+		l = l[loc[1]:]
+	}
+
+	if loc := checked.FindStringIndex(l); loc != nil {
+		r.checked = true
+		l = l[:loc[0]] + l[loc[1]:]
+	} else if loc := unchecked.FindStringIndex(l); loc != nil {
+		l = l[:loc[0]] + l[loc[1]:]
+	}
+
+	r.option = strings.TrimSpace(l)
+	return r
+}
+
+func (d *Driver) cursor() *regexp.Regexp {
+	if d.Cursor == nil {
+		return DefaultCursor
+	}
+
+	return d.Cursor
+}
+
+func (d *Driver) checked() *regexp.Regexp {
+	if d.Checked == nil {
+		return DefaultChecked
+	}
+
+	return d.Checked
+}
+
+func (d *Driver) unchecked() *regexp.Regexp {
+	if d.Unchecked == nil {
+		return DefaultUnchecked
+	}
+
+	return d.Unchecked
+}
+
+// lines of the output without ANSI escape sequences,
+// keeping what is written after carriage returns
+func lines(out string) []string {
+	var ls = strings.Split(ansi.ReplaceAllString(out, ""), "\n")
+
+	for c, l := range ls {
+		l = strings.TrimRight(l, "\r")
+
+		if i := strings.LastIndex(l, "\r"); i != -1 {
+			l = l[i+1:]
+		}
+
+		ls[c] = strings.TrimRight(l, " ")
+	}
+
+	return ls
+}
+
+func cursor(rows []row) int {
+	for c, r := range rows {
+		if r.cursor {
+			return c
+		}
+	}
+
+	return -1
+}
+
+func index(rows []row, option string) int {
+	for c, r := range rows {
+		if r.option == option {
+			return c
+		}
+	}
+
+	return -1
+}
+
+func contains(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAll(l string, options []string) bool {
+	for _, o := range options {
+		if !strings.Contains(l, o) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,91 @@
+//go:build !windows
+// +build !windows
+
+package menu
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+)
+
+func TestSelectAndMultiSelect(t *testing.T) {
+	var term = &pseudoterm.Terminal{
+		Command: exec.Command("../mocks/mock-select.sh"),
+	}
+
+	var d = &Driver{Terminal: term}
+	var out = &bytes.Buffer{}
+	term.EchoStream = io.MultiWriter(d, out)
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	defer term.Stop()
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := d.Select(ctx, "Choose a region", "eu-west-1"); err != nil {
+		t.Fatalf("Expected no error selecting, got %v instead", err)
+	}
+
+	if err := d.MultiSelect(ctx, "Select features", []string{"logging", "tracing"}); err != nil {
+		t.Fatalf("Expected no error selecting, got %v instead", err)
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	if want := "Deploying to eu-west-1 with logging tracing"; !strings.Contains(out.String(), want) {
+		t.Errorf("Expected output to contain %q, got %q instead", want, out.String())
+	}
+}
+
+func TestSelectNotFound(t *testing.T) {
+	var term = &pseudoterm.Terminal{
+		Command: exec.Command("../mocks/mock-select.sh"),
+	}
+
+	var d = &Driver{Terminal: term}
+	term.EchoStream = d
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	defer term.Stop()
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var err = d.Select(ctx, "Choose a region", "mars-1")
+
+	if err == nil || !strings.Contains(err.Error(), `Option "mars-1" not found on menu "Choose a region"`) {
+		t.Errorf("Expected option not found error, got %v instead", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	var d = &Driver{}
+
+	var out = "? Pick one:\r\n" +
+		"\x1b[2K\x1b[36m> [x] a\x1b[0m\r\n\x1b[2K  [ ] b\r\n" +
+		"\x1b[2A\x1b[2K  [x] a\r\n\x1b[2K❯ ◉ b\r\n" +
+		"\x1b[2K  [ ] c"
+
+	var rows = d.parse(out, "Pick one")
+
+	if len(rows) != 2 ||
+		rows[0] != (row{option: "a", checked: true}) ||
+		rows[1] != (row{option: "b", cursor: true, checked: true}) {
+		t.Errorf("Unexpected rows %+v", rows)
+	}
+}
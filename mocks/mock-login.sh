@@ -0,0 +1,22 @@
+#!/bin/bash
+
+# this mock is used to test scripts retrying a login
+
+set -euo pipefail
+IFS=$'\n\t'
+
+for i in 1 2 3; do
+  read -p "Password: " PASSWORD < /dev/tty;
+
+  if [[ $PASSWORD == "secret" ]]; then
+    echo "Welcome!"
+    read -p "Token: " TOKEN < /dev/tty;
+    echo "Token is $TOKEN"
+    exit 0
+  fi
+
+  echo "Try again"
+done
+
+echo "Too many attempts"
+exit 1
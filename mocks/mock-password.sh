@@ -0,0 +1,12 @@
+#!/bin/bash
+
+set -euo pipefail
+IFS=$'\n\t'
+
+echo "Starting"
+read -p "Your name: " YOUR_NAME
+read -s -p "Secret code, please: " YOUR_CODE
+echo
+echo "Hello $YOUR_NAME, your code has ${#YOUR_CODE} characters"
+
+sleep 0.1
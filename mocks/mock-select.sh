@@ -0,0 +1,110 @@
+#!/bin/bash
+
+# renders arrow-key menus like prompt libraries do
+
+regions=("us-east-1" "us-west-2" "eu-west-1" "ap-south-1")
+features=("logging" "metrics" "tracing" "alerts")
+checked=(0 1 0 0)
+cursor=0
+
+render_select() {
+	for i in "${!regions[@]}"; do
+		if [ "$i" -eq "$cursor" ]; then
+			printf '\e[2K\e[36m> %s\e[0m\n' "${regions[$i]}"
+		else
+			printf '\e[2K  %s\n' "${regions[$i]}"
+		fi
+	done
+}
+
+render_multi() {
+	for i in "${!features[@]}"; do
+		local box="[ ]"
+		local prefix=" "
+
+		if [ "${checked[$i]}" -eq 1 ]; then
+			box="[x]"
+		fi
+
+		if [ "$i" -eq "$cursor" ]; then
+			prefix=">"
+		fi
+
+		printf '\e[2K%s %s %s\n' "$prefix" "$box" "${features[$i]}"
+	done
+}
+
+# read_key sets key to up, down, space or enter
+read_key() {
+	local k
+	IFS= read -rsn1 k
+
+	case "$k" in
+	$'\e')
+		IFS= read -rsn2 k
+		case "$k" in
+		'[A') key=up ;;
+		'[B') key=down ;;
+		*) key=other ;;
+		esac
+		;;
+	' ') key=space ;;
+	'' | $'\r' | $'\n') key=enter ;;
+	*) key=other ;;
+	esac
+}
+
+stty -echo -icanon -icrnl
+
+echo "Starting"
+printf '? Choose a region: [Use arrows to move]\n'
+render_select
+
+while true; do
+	read_key
+
+	case "$key" in
+	up) [ "$cursor" -gt 0 ] && cursor=$((cursor - 1)) ;;
+	down) [ "$cursor" -lt $((${#regions[@]} - 1)) ] && cursor=$((cursor + 1)) ;;
+	enter) break ;;
+	esac
+
+	printf '\e[%dA' "${#regions[@]}"
+	render_select
+done
+
+printf '\e[%dA\e[J' "$((${#regions[@]} + 1))"
+printf '? Choose a region: %s\n' "${regions[$cursor]}"
+
+region="${regions[$cursor]}"
+cursor=0
+printf '? Select features: [Use space to toggle]\n'
+render_multi
+
+while true; do
+	read_key
+
+	case "$key" in
+	up) [ "$cursor" -gt 0 ] && cursor=$((cursor - 1)) ;;
+	down) [ "$cursor" -lt $((${#features[@]} - 1)) ] && cursor=$((cursor + 1)) ;;
+	space) checked[$cursor]=$((1 - checked[$cursor])) ;;
+	enter) break ;;
+	esac
+
+	printf '\e[%dA' "${#features[@]}"
+	render_multi
+done
+
+selected=()
+
+for i in "${!features[@]}"; do
+	if [ "${checked[$i]}" -eq 1 ]; then
+		selected+=("${features[$i]}")
+	fi
+done
+
+printf '\e[%dA\e[J' "$((${#features[@]} + 1))"
+printf '? Select features: %s\n' "$(IFS=,; echo "${selected[*]}" | sed 's/,/, /g')"
+
+stty sane
+echo "Deploying to $region with ${selected[*]}"
@@ -0,0 +1,17 @@
+#!/bin/bash
+
+set -euo pipefail
+IFS=$'\n\t'
+
+echo "Starting"
+echo "Warning: no config" >&2
+
+sleep 0.1
+
+printf "Your name: "
+read YOUR_NAME
+echo "Hi $YOUR_NAME"
+echo "Error: $YOUR_NAME not found" >&2
+
+sleep 0.1
+exit 1
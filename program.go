@@ -0,0 +1,135 @@
+package pseudoterm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ProgramFunc plays an interactive program, reading its input from stdin
+// and writing its output to stdout. It returns the exit code of the program.
+type ProgramFunc func(stdin io.Reader, stdout io.Writer) (exitCode int)
+
+// Program is an in-memory Transport where a ProgramFunc plays the program,
+// so stories can be tested fast without spawning processes.
+// Writing EOT closes the input of the program, like on a pseudo tty.
+// Echo writes the input back to the output, like a pseudo tty does.
+type Program struct {
+	Func     ProgramFunc
+	Echo     bool
+	stdin    *pipe
+	stdout   *pipe
+	exitCode int
+	done     chan empty
+}
+
+// Start the program
+func (p *Program) Start() error {
+	if p.done != nil {
+		return errors.New("Already started")
+	}
+
+	p.stdin, p.stdout = newPipe(), newPipe()
+	p.done = make(chan empty)
+
+	go func() {
+		defer close(p.done)
+		p.exitCode = p.Func(p.stdin, p.stdout)
+		p.stdout.Close()
+	}()
+
+	return nil
+}
+
+// Read the output of the program
+func (p *Program) Read(b []byte) (n int, err error) {
+	return p.stdout.Read(b)
+}
+
+// Write input to the program
+func (p *Program) Write(b []byte) (n int, err error) {
+	var in = b
+	var eot = bytes.IndexByte(b, EOT[0])
+
+	if eot != -1 {
+		in = b[:eot]
+	}
+
+	if _, err = p.stdin.Write(in); err != nil {
+		return 0, err
+	}
+
+	if p.Echo {
+		_, _ = p.stdout.Write(in)
+	}
+
+	if eot != -1 {
+		p.stdin.Close()
+	}
+
+	return len(b), nil
+}
+
+// Close the input and output of the program
+func (p *Program) Close() error {
+	p.stdin.Close()
+	p.stdout.Close()
+	return nil
+}
+
+// Wait for the program to end
+func (p *Program) Wait() (exitCode int, err error) {
+	<-p.done
+	return p.exitCode, nil
+}
+
+// pipe is an in-memory pipe where writing never blocks
+type pipe struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	b      bytes.Buffer
+	closed bool
+}
+
+func newPipe() *pipe {
+	var p = &pipe{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *pipe) Read(b []byte) (n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for p.b.Len() == 0 && !p.closed {
+		p.cond.Wait()
+	}
+
+	if p.b.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	return p.b.Read(b)
+}
+
+func (p *pipe) Write(b []byte) (n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	n, _ = p.b.Write(b)
+	p.cond.Broadcast()
+	return n, nil
+}
+
+func (p *pipe) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	p.cond.Broadcast()
+}
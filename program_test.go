@@ -0,0 +1,144 @@
+package pseudoterm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func greeter(stdin io.Reader, stdout io.Writer) int {
+	var r = bufio.NewReader(stdin)
+
+	fmt.Fprint(stdout, "Your name: ")
+	name, _ := r.ReadString('\n')
+
+	fmt.Fprint(stdout, "Your age: ")
+	age, _ := r.ReadString('\n')
+
+	fmt.Fprintf(stdout, "%s is %s\n", strings.TrimSpace(name), strings.TrimSpace(age))
+	return 3
+}
+
+func TestProgramWithStory(t *testing.T) {
+	var bf = &bytes.Buffer{}
+	var term = &Terminal{
+		Transport:  &Program{Func: greeter, Echo: true},
+		EchoStream: bf,
+	}
+
+	var story = &QueueStory{
+		Timeout: time.Second,
+	}
+
+	story.Add(
+		Step{
+			Read:  "Your name:",
+			Write: "Henrique",
+		},
+		Step{
+			Read:  "Your age:",
+			Write: "10",
+		},
+		Step{
+			Read:      "Henrique is 10",
+			SkipWrite: true,
+		})
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Expected story to succeed")
+	}
+
+	if ps := term.Wait(); ps != nil {
+		t.Errorf("Expected no process state, got %v instead", ps)
+	}
+
+	if code := term.ExitCode(); code != 3 {
+		t.Errorf("Expected exit code 3, got %v instead", code)
+	}
+
+	<-term.OutputDone()
+
+	var want = "Your name: Henrique\nYour age: 10\nHenrique is 10\n"
+
+	if bf.String() != want {
+		t.Errorf("Expected output to be %q, got %q instead", want, bf.String())
+	}
+}
+
+func TestProgramStopClosesInput(t *testing.T) {
+	var term = &Terminal{
+		Transport: &Program{
+			Func: func(stdin io.Reader, stdout io.Writer) int {
+				var b, _ = ioutil.ReadAll(stdin)
+				return len(b)
+			},
+		},
+	}
+
+	if code := term.ExitCode(); code != -1 {
+		t.Errorf("Expected exit code -1 before starting, got %v instead", code)
+	}
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if _, err := term.WriteString("abc"); err != nil {
+		t.Errorf("Expected no error, got %v instead", err)
+	}
+
+	if err := term.Stop(); err != nil {
+		t.Errorf("Expected no error, got %v instead", err)
+	}
+
+	term.Wait()
+
+	if code := term.ExitCode(); code != 3 {
+		t.Errorf("Expected exit code 3, got %v instead", code)
+	}
+}
+
+func TestProgramAlreadyStarted(t *testing.T) {
+	var p = &Program{
+		Func: func(stdin io.Reader, stdout io.Writer) int {
+			return 0
+		},
+	}
+
+	if err := p.Start(); err != nil {
+		t.Errorf("Expected no error, got %v instead", err)
+	}
+
+	if err := p.Start(); err == nil || err.Error() != "Already started" {
+		t.Errorf("Expected already started error, got %v instead", err)
+	}
+}
+
+func TestProgramSetSizeUnsupported(t *testing.T) {
+	var term = &Terminal{
+		Transport: &Program{
+			Func: func(stdin io.Reader, stdout io.Writer) int {
+				return 0
+			},
+		},
+	}
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if err := term.SetSize(24, 80); err != ErrUnsupported {
+		t.Errorf("Expected error to be %v, got %v instead", ErrUnsupported, err)
+	}
+
+	term.Wait()
+}
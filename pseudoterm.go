@@ -1,19 +1,21 @@
 /*
 Package pseudoterm is a framework for running iterative programs programmatically with Go.
+
 	https://github.com/henvic/picel
 */
 package pseudoterm
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kr/pty"
@@ -35,20 +37,75 @@ var (
 	// that there are no more steps left to be dealt with.
 	SkipZeroMatches = errors.New("Skip line input due to no match available")
 
+	// WriteRaw is used as a return value from Story HandleLine to indicate
+	// that the input should be written as is, without a line break.
+	WriteRaw = errors.New("Write input without line break")
+
+	// SecretWrite is used as a return value from Story HandleLine to indicate
+	// that the input is a secret line (see Terminal.WriteSecret).
+	SecretWrite = errors.New("Write input as a secret line")
+
+	// EndStory is used as a return value from Story methods to indicate
+	// that Watch should return, leaving the program running for another story.
+	EndStory = errors.New("End story")
+
+	// StopGracePeriod is how long Stop waits for the program to end before killing it
+	StopGracePeriod = 5 * time.Second
+
+	// ErrStopTimeout is returned by Stop when the program or the copy of its output
+	// doesn't end in time and can't be killed
+	ErrStopTimeout = errors.New("Timed out waiting for the program to stop")
+
 	// ErrUnsupported is used to indicate there is
 	ErrUnsupported = pty.ErrUnsupported
 )
 
 // Terminal is a pseudo terminal you can use to run commands
-// on a pseudo tty programmatically
+// on a pseudo tty programmatically.
+// Rows and Cols set the size of the pseudo tty, if not zero.
+// Transport replaces the pseudo tty running Command, if set.
+// Clock is used to wait between checks for output (default: SystemClock).
+// SeparateStderr attaches the standard error of the program to a pipe
+// instead of the pseudo tty, echoing it to StderrEchoStream.
+// Logger receives events such as writes and the program exit, if set.
+// Secrets are scrubbed from EchoStream, StderrEchoStream, the Logger and
+// execution errors (see WriteSecret).
+// BufferSize limits the output not handled yet, in bytes per stream,
+// and Overflow tells what happens when it is full. Zero means no limit.
+// SkipBuffer doesn't keep the output for Watch, for programs driven from
+// the EchoStream or a Subscription instead (like with Recorder).
 type Terminal struct {
-	Command         *exec.Cmd
-	EchoStream      io.Writer
-	CopyStreamError error
-	processState    *os.ProcessState
-	terminal        *os.File
-	bfs             *bytes.Buffer
-	end             chan empty
+	Command          *exec.Cmd
+	Transport        Transport
+	Clock            Clock
+	EchoStream       io.Writer
+	CopyStreamError  error
+	Rows             uint16
+	Cols             uint16
+	SeparateStderr   bool
+	StderrEchoStream io.Writer
+	Logger           *slog.Logger
+	Secrets          *Secrets
+	BufferSize       int
+	Overflow         OverflowPolicy
+	SkipBuffer       bool
+	processState     *os.ProcessState
+	exitCode         int
+	waitErr          error
+	transport        Transport
+	bfs              *outputBuffer
+	ebfs             *outputBuffer
+	end              chan empty
+	copyDone         chan struct{}
+	stateMu          sync.Mutex
+	stateChans       []chan<- State
+	lastState        *State
+	tapMu            sync.Mutex
+	tap              io.Writer
+	subMu            sync.Mutex
+	subs             []*Subscription
+	subEnded         bool
+	started          time.Time
 }
 
 // Story is interface you can implement to handle commands
@@ -59,10 +116,36 @@ type Story interface {
 	HandleLine(s string) (in string, err error)
 }
 
-// ExecutionError indicates if any happened during the execution
+// OutputStream is the stream a line is printed on
+type OutputStream int
+
+const (
+	// AnyStream is used for lines when stdout and stderr are combined,
+	// and on steps to match lines printed on any stream
+	AnyStream OutputStream = iota
+
+	// StdoutStream is the standard output
+	StdoutStream
+
+	// StderrStream is the standard error, when using Terminal SeparateStderr
+	StderrStream
+)
+
+// StreamStory is implemented by stories handling lines depending on the stream they
+// are printed on. HandleStreamLine is called instead of HandleLine.
+type StreamStory interface {
+	Story
+	HandleStreamLine(s string, stream OutputStream) (in string, err error)
+}
+
+// ExecutionError indicates if any happened during the execution.
+// ExitError is set when the program has ended, but the transport
+// couldn't tell how (such as when a connection is lost).
 type ExecutionError struct {
 	RunError     error
 	SigtermError error
+	ExitError    error
+	secrets      *Secrets
 }
 
 func (e ExecutionError) Error() string {
@@ -75,7 +158,11 @@ func (e ExecutionError) Error() string {
 		msgs = append(msgs, "SIGTERM signal error: "+e.SigtermError.Error())
 	}
 
-	return strings.Join(msgs, "; ")
+	if e.ExitError != nil {
+		msgs = append(msgs, "Exit error: "+e.ExitError.Error())
+	}
+
+	return e.secrets.Redact(strings.Join(msgs, "; "))
 }
 
 type empty struct{}
@@ -88,76 +175,266 @@ func (t *Terminal) Run(story Story) (err error) {
 
 	err = t.Watch(story)
 	var et = t.Stop()
+	var ee error
+
+	if t.ended() {
+		ee = t.waitErr
+	}
 
-	if err == nil && et == nil {
+	if err == nil && et == nil && ee == nil {
 		return nil
 	}
 
 	return ExecutionError{
 		RunError:     err,
 		SigtermError: et,
+		ExitError:    ee,
+		secrets:      t.Secrets,
 	}
 }
 
-// Stop the program
+// Stop the program and wait for it to end and its output to be copied.
+// The program is killed if it doesn't end within StopGracePeriod.
 func (t *Terminal) Stop() (err error) {
-	if t.processState == nil {
-		if _, err = t.Write(EOT); err != nil {
-			return err
+	if t.transport == nil {
+		return nil
+	}
+
+	t.closeBuffers()
+
+	var running = !t.ended()
+
+	if running {
+		_, err = t.Write(EOT)
+	}
+
+	// close it even after the program ends, releasing the pseudo tty
+	if ec := t.transport.Close(); running && err == nil {
+		err = ec
+	}
+
+	var grace = time.After(StopGracePeriod)
+
+	select {
+	case <-t.end:
+	case <-grace:
+		if ek := t.kill(); ek != nil {
+			return ek
 		}
 
-		return t.terminal.Close()
+		grace = time.After(StopGracePeriod)
+	}
+
+	select {
+	case <-t.copyDone:
+	case <-grace:
+		return ErrStopTimeout
 	}
 
 	return err
 }
 
+// kill the program and wait for it to end
+func (t *Terminal) kill() error {
+	var s, ok = t.transport.(signaler)
+
+	if !ok {
+		return ErrStopTimeout
+	}
+
+	t.log().Warn("Killing program", "grace_period", StopGracePeriod)
+
+	if err := s.Signal(os.Kill); err != nil {
+		return err
+	}
+
+	// the output is copied until the pseudo tty is closed
+	_ = t.transport.Close()
+	<-t.end
+	return nil
+}
+
 // Start the program
 func (t *Terminal) Start() (err error) {
-	if t.terminal != nil {
+	if t.transport != nil {
 		return errors.New("Already started")
 	}
 
-	t.end = make(chan empty, 1)
-	t.terminal, err = pty.Start(t.Command)
+	t.transport = t.Transport
 
-	if err == nil {
-		t.copyStreamToBuffer()
+	if t.Secrets == nil {
+		t.Secrets = &Secrets{}
+	}
 
-		go func() {
-			// we don't care if process was terminated correctly or not
-			// as we only care about having an open connection to it or not
-			t.processState, _ = t.Command.Process.Wait()
-			t.end <- empty{}
-		}()
+	if t.transport == nil {
+		t.transport = &ptyTransport{
+			cmd:            t.Command,
+			rows:           t.Rows,
+			cols:           t.Cols,
+			separateStderr: t.SeparateStderr,
+		}
 	}
 
-	return err
+	if _, ok := t.transport.(stderrTransport); t.SeparateStderr && !ok {
+		t.transport = nil
+		return errors.New("Transport doesn't support SeparateStderr")
+	}
+
+	t.end = make(chan empty)
+
+	if r, ok := t.transport.(resizer); ok && t.Transport != nil && (t.Rows != 0 || t.Cols != 0) {
+		if err = r.SetSize(t.Rows, t.Cols); err != nil {
+			t.transport = nil
+			return err
+		}
+	}
+
+	if err = t.transport.Start(); err != nil {
+		t.transport = nil
+		t.log().Error("Program failed to start", "error", err)
+		return err
+	}
+
+	t.started = t.clock().Now()
+	t.log().Info("Program started", "program", t.program())
+	t.copyStreamToBuffer()
+
+	go func() {
+		t.exitCode, t.waitErr = t.transport.Wait()
+
+		if p, ok := t.transport.(processStater); ok {
+			t.processState = p.ProcessState()
+		}
+
+		var attrs = []interface{}{"exit_code", t.exitCode, "elapsed", t.clock().Now().Sub(t.started)}
+
+		if t.waitErr != nil {
+			attrs = append(attrs, "error", t.waitErr)
+		}
+
+		t.log().Info("Program exited", attrs...)
+		close(t.end)
+	}()
+
+	return nil
 }
 
-// Wait for process to end and return process state
+// SetSize resizes the pseudo tty.
+// Transports other than the pseudo tty might not support it.
+func (t *Terminal) SetSize(rows, cols uint16) error {
+	t.Rows, t.Cols = rows, cols
+
+	if t.transport == nil {
+		return nil
+	}
+
+	if r, ok := t.transport.(resizer); ok {
+		return r.SetSize(rows, cols)
+	}
+
+	return ErrUnsupported
+}
+
+// Signal sends a signal to the program.
+// Transports other than the pseudo tty and pipes might not support it.
+func (t *Terminal) Signal(sig os.Signal) error {
+	if s, ok := t.transport.(signaler); ok {
+		return s.Signal(sig)
+	}
+
+	return ErrUnsupported
+}
+
+// Wait for process to end and return process state.
+// The process state is nil for transports not running a local process.
 func (t *Terminal) Wait() (ps *os.ProcessState) {
 	<-t.end
 	return t.processState
 }
 
+// ExitCode of the program, or -1 if it hasn't ended
+func (t *Terminal) ExitCode() int {
+	if !t.ended() {
+		return -1
+	}
+
+	return t.exitCode
+}
+
+func (t *Terminal) ended() bool {
+	select {
+	case <-t.end:
+		return true
+	default:
+		return false
+	}
+}
+
+// OutputDone is closed once all the output of the program is copied,
+// what happens after it ends or the terminal is stopped
+func (t *Terminal) OutputDone() <-chan struct{} {
+	return t.copyDone
+}
+
 // Write bytes to the pseudo terminal
 func (t *Terminal) Write(b []byte) (n int, err error) {
-	return t.terminal.Write(b)
+	n, err = t.transport.Write(b)
+
+	if err != nil {
+		t.log().Warn("Write failed", "input", string(b), "bytes", n, "error", err)
+		return n, err
+	}
+
+	t.log().Debug("Wrote input", "input", string(b), "bytes", n)
+	return n, nil
 }
 
 // WriteString to the pseudo terminal
 func (t *Terminal) WriteString(s string) (n int, err error) {
-	return t.terminal.WriteString(s)
+	return t.Write([]byte(s))
 }
 
 // WriteLine to the pseudo terminal
 func (t *Terminal) WriteLine(s string) (n int, err error) {
-	return t.terminal.WriteString(s + "\n")
+	return t.WriteString(s + "\n")
 }
 
-// Watch starts handling lines printed by the program
+// WriteSecret writes a line to the pseudo terminal, adding it to the Secrets,
+// so it is scrubbed from the echo, logs and errors
+func (t *Terminal) WriteSecret(s string) (n int, err error) {
+	t.Secrets.Add(s)
+	return t.WriteLine(s)
+}
+
+// log with the secrets redacted
+func (t *Terminal) log() *slog.Logger {
+	if t.Logger == nil || t.Secrets == nil {
+		return logger(t.Logger)
+	}
+
+	return slog.New(t.Secrets.Handler(t.Logger.Handler()))
+}
+
+// program name for logging
+func (t *Terminal) program() string {
+	switch {
+	case t.Transport != nil:
+		return fmt.Sprintf("%T", t.Transport)
+	case t.Command != nil:
+		return strings.Join(t.Command.Args, " ")
+	default:
+		return ""
+	}
+}
+
+// Watch starts handling lines printed by the program.
+// It returns once the program ends, the story ends (see EndStory) or its context is done,
+// after the line being handled, if any.
 func (t *Terminal) Watch(s Story) error {
+	if t.SkipBuffer {
+		return errSkipBuffer
+	}
+
 	defer s.Teardown()
 	var ctx, err = s.Setup()
 
@@ -167,99 +444,292 @@ func (t *Terminal) Watch(s Story) error {
 
 	var ok bool
 	var endReadLine = make(chan empty, 1)
-
-	go func() {
+	var read = func() {
 		ok, err = t.readLine(s)
 		endReadLine <- empty{}
-	}()
+	}
+
+	go read()
 
 	for {
 		select {
 		case <-ctx.Done():
+			<-endReadLine
+			t.log().Warn("Story ended", "error", ctx.Err())
 			return ctx.Err()
 		case <-endReadLine:
+			if err == EndStory {
+				return nil
+			}
+
 			if ok || err != nil {
 				return err
 			}
 
-			go func() {
-				ok, err = t.readLine(s)
-				endReadLine <- empty{}
-			}()
+			go read()
 		default:
-			time.Sleep(LineReaderInterval)
+			t.sleep(ctx)
 		}
 	}
 }
 
+// sleep for LineReaderInterval, unless the context is done
+func (t *Terminal) sleep(ctx context.Context) {
+	var timer = t.clock().NewTimer(LineReaderInterval)
+
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+	case <-timer.C():
+	}
+}
+
+func (t *Terminal) clock() Clock {
+	if t.Clock == nil {
+		return SystemClock
+	}
+
+	return t.Clock
+}
+
 func (t *Terminal) copyStreamToBuffer() {
-	t.bfs = &bytes.Buffer{}
+	t.bfs = newOutputBuffer(t.BufferSize, t.Overflow)
+	t.copyDone = make(chan struct{})
+
+	var stream = AnyStream
+
+	if t.SeparateStderr {
+		stream = StdoutStream
+	}
+
+	// the fields are read before returning, so they can be changed once started
+	var echo = newScrubber(outputWriter{t, t.EchoStream, stream}, t.Secrets)
+	var out = t.buffer(t.bfs)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
 
 	go func() {
-		if t.EchoStream == nil {
-			_, t.CopyStreamError = io.Copy(t.bfs, t.terminal)
-		} else {
-			var tee = io.TeeReader(t.terminal, t.EchoStream)
-			_, t.CopyStreamError = io.Copy(t.bfs, tee)
+		defer wg.Done()
+
+		var tee = io.TeeReader(t.transport, echoWriter{t, echo})
+		_, t.CopyStreamError = io.Copy(out, tee)
+
+		if err := echo.Flush(); err != nil && t.CopyStreamError == nil {
+			t.CopyStreamError = err
 		}
 	}()
+
+	if t.SeparateStderr {
+		t.ebfs = newOutputBuffer(t.BufferSize, t.Overflow)
+		var r io.Reader = t.transport.(stderrTransport).Stderr()
+
+		var echo = newScrubber(outputWriter{t, t.StderrEchoStream, StderrStream}, t.Secrets)
+		var out = t.buffer(t.ebfs)
+		r = io.TeeReader(r, echo)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			_, _ = io.Copy(out, r)
+			_ = echo.Flush()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		t.endSubscriptions()
+		close(t.copyDone)
+	}()
+}
+
+// buffer to keep the output for Watch, unless using SkipBuffer
+func (t *Terminal) buffer(b *outputBuffer) io.Writer {
+	if t.SkipBuffer {
+		return io.Discard
+	}
+
+	return b
+}
+
+// echoWriter writes the output of the program to the EchoStream and the subscribers
+// and, while interacting, to the user
+type echoWriter struct {
+	t    *Terminal
+	echo *scrubber
+}
+
+func (e echoWriter) Write(p []byte) (n int, err error) {
+	e.t.tapMu.Lock()
+
+	if e.t.tap != nil {
+		_, _ = e.t.tap.Write(p)
+	}
+
+	e.t.tapMu.Unlock()
+	return e.echo.Write(p)
+}
+
+// DroppedBytes of output not handled due to the OverflowDropOldest policy
+func (t *Terminal) DroppedBytes() (n int64) {
+	for _, b := range []*outputBuffer{t.bfs, t.ebfs} {
+		if b != nil {
+			n += b.Dropped()
+		}
+	}
+
+	return n
+}
+
+func (t *Terminal) bufferErr() error {
+	for _, b := range []*outputBuffer{t.bfs, t.ebfs} {
+		if b == nil {
+			continue
+		}
+
+		if err := b.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// closeBuffers as no more lines are handled
+func (t *Terminal) closeBuffers() {
+	for _, b := range []*outputBuffer{t.bfs, t.ebfs} {
+		if b != nil {
+			b.Close()
+		}
+	}
 }
 
 func (t *Terminal) readLine(s Story) (end bool, err error) {
-	if t.processState != nil {
+	// handle lines left on the buffer before ending
+	if t.ended() && t.bfs.Len() == 0 && (t.ebfs == nil || t.ebfs.Len() == 0) {
 		return true, nil
 	}
 
-	line, err := t.bfs.ReadString('\n')
+	if err := t.bufferErr(); err != nil {
+		return false, err
+	}
 
-	if err != nil && err != io.EOF {
+	if err := s.TickHandler(); err != nil {
 		return false, err
 	}
 
+	if err := t.handleState(s); err != nil {
+		return false, err
+	}
+
+	// the TickHandler is called before reading each line, so a line printed
+	// after the story ends (see EndStory) is left for the next story
 	if err := s.TickHandler(); err != nil {
 		return false, err
 	}
 
-	return t.handleLine(line, s)
+	if t.ebfs == nil {
+		return t.handleLine(t.bfs.ReadString('\n'), AnyStream, s)
+	}
+
+	if end, err = t.handleLine(t.ebfs.ReadString('\n'), StderrStream, s); end || err != nil {
+		return end, err
+	}
+
+	if err := s.TickHandler(); err != nil {
+		return false, err
+	}
+
+	return t.handleLine(t.bfs.ReadString('\n'), StdoutStream, s)
 }
 
-func (t *Terminal) handleLine(line string, s Story) (end bool, err error) {
+func (t *Terminal) handleLine(line string, stream OutputStream, s Story) (end bool, err error) {
 	if len(line) != 0 {
-		in, err := s.HandleLine(line)
+		var in string
 
-		switch {
-		case err == SkipWrite || err == SkipZeroMatches:
-		case err == nil:
-			if _, e := t.WriteLine(in); e != nil {
-				return false, e
-			}
-		default:
-			return false, err
+		if ss, ok := s.(StreamStory); ok {
+			in, err = ss.HandleStreamLine(line, stream)
+		} else {
+			in, err = s.HandleLine(line)
 		}
+
+		return false, t.handleInput(in, err)
 	}
 
 	return false, nil
 }
 
+// handleInput writes the input returned by a story, depending on the error value
+func (t *Terminal) handleInput(in string, err error) error {
+	switch {
+	case err == SkipWrite:
+	case err == SkipZeroMatches:
+		t.log().Debug("Skipped line", "reason", "no steps left")
+	case err == WriteRaw:
+		if _, e := t.WriteString(in); e != nil {
+			return e
+		}
+	case err == SecretWrite:
+		if _, e := t.WriteSecret(in); e != nil {
+			return e
+		}
+	case err == nil:
+		if _, e := t.WriteLine(in); e != nil {
+			return e
+		}
+	default:
+		return err
+	}
+
+	return nil
+}
+
 // QueueStory is a command execution story with sequential steps
-// that must be fulfilled before the next is executed
+// that must be fulfilled before the next is executed.
+// Clock is used for the timeouts (default: SystemClock).
+// Logger receives events such as matches and timeouts, if set.
+// ReturnOnSuccess ends the story once all steps are done,
+// instead of when the program ends, so another story can be watched.
 type QueueStory struct {
-	Sequence      []Step
-	Timeout       time.Duration
-	pastStepTime  time.Time
-	ctx           context.Context
-	ctxCancelFunc context.CancelFunc
+	Sequence        []Step
+	Timeout         time.Duration
+	Clock           Clock
+	Logger          *slog.Logger
+	ReturnOnSuccess bool
+	done            int
+	pastStepTime    time.Time
+	state           State
+	armed           bool
+	ctx             context.Context
+	ctxCancelFunc   context.CancelFunc
 }
 
-// Step is like a route rule to handle lines
+// Step is like a route rule to handle lines.
+// Stream restricts the lines to those printed on a given stream.
+// NoEcho restricts the step to when the terminal echo is disabled, as when a
+// program asks for a password. InputRequested restricts it to when the program
+// is waiting for input (see State). Foreground restricts it to when the program
+// name or command line of the foreground process group leader is the given one,
+// as when a command run by a shell finishes. A step matching a line waits for these
+// conditions before writing, and a step without Read, ReadRegex or ReadFunc
+// matches as soon as they are true, regardless of the lines printed.
+// Secret marks Write as a secret, scrubbed from the echo, logs and errors
+// (see Terminal.WriteSecret). It can't be used with Keys.
 type Step struct {
-	Read       string
-	ReadRegex  *regexp.Regexp
-	ReadFunc   func(in string) bool
-	Write      string
-	SkipWrite  bool
-	Timeout    time.Duration
-	timeoutCtx context.Context
+	Read           string
+	ReadRegex      *regexp.Regexp
+	ReadFunc       func(in string) bool
+	Stream         OutputStream
+	NoEcho         bool
+	InputRequested bool
+	Foreground     string
+	Write          string
+	Secret         bool
+	Keys           []string
+	SkipWrite      bool
+	Timeout        time.Duration
+	timeoutCtx     context.Context
 }
 
 var errAlreadyInitialized = errors.New("Story has already initialized")
@@ -275,11 +745,11 @@ func (q *QueueStory) Setup() (ctx context.Context, err error) {
 		return nil, errAlreadyInitialized
 	}
 
-	q.pastStepTime = time.Now()
+	q.pastStepTime = q.clock().Now()
 	q.ctx, q.ctxCancelFunc = context.WithCancel(context.Background())
 
 	if q.Timeout != time.Duration(0) {
-		q.ctx, q.ctxCancelFunc = context.WithTimeout(q.ctx, q.Timeout)
+		q.ctx, q.ctxCancelFunc = withTimeout(q.ctx, q.clock(), q.Timeout)
 	}
 
 	return q.ctx, nil
@@ -300,6 +770,10 @@ func (q *QueueStory) Teardown() {
 // TickHandler is called on terminal Watch between LineReaderInterval
 // regardless if there are changes or not, before HandleLine
 func (q *QueueStory) TickHandler() error {
+	if len(q.Sequence) == 0 && q.ReturnOnSuccess {
+		return EndStory
+	}
+
 	if len(q.Sequence) == 0 {
 		return nil
 	}
@@ -310,38 +784,117 @@ func (q *QueueStory) TickHandler() error {
 		return nil
 	}
 
-	if time.Now().Before(q.pastStepTime.Add(step.Timeout)) {
+	if q.clock().Now().Before(q.pastStepTime.Add(step.Timeout)) {
 		return nil
 	}
 
 	q.ctx, q.ctxCancelFunc = context.WithDeadline(q.ctx, time.Time{})
+	logger(q.Logger).Warn("Step timed out", "step", q.done, "timeout", step.Timeout)
 
-	return fmt.Errorf("Timed out while waiting for line \"%v\": timeout %v",
-		q.Sequence[0].Read,
-		q.Sequence[0].Timeout)
+	return fmt.Errorf("Timed out while waiting for %v: timeout %v",
+		step.description(),
+		step.Timeout)
 }
 
 // HandleLine handles a QueueStory line the program prints
 func (q *QueueStory) HandleLine(s string) (in string, err error) {
+	return q.HandleStreamLine(s, AnyStream)
+}
+
+// HandleStreamLine handles a QueueStory line the program prints on a given stream
+func (q *QueueStory) HandleStreamLine(s string, stream OutputStream) (in string, err error) {
 	if len(q.Sequence) == 0 {
 		return "", SkipZeroMatches
 	}
 
-	if match := q.matcher(s, q.Sequence[0]); !match {
+	if match := q.matcher(s, stream, q.Sequence[0]); !match {
 		return "", SkipWrite
 	}
 
+	if !q.stateMatcher(q.Sequence[0]) {
+		// wait for the state
+		q.armed = true
+		logger(q.Logger).Debug("Step waiting for state", "step", q.done, "line", s)
+		return "", SkipWrite
+	}
+
+	return q.next(s)
+}
+
+// NeedsState tells if the current step matches the state of the terminal
+func (q *QueueStory) NeedsState() bool {
+	return len(q.Sequence) != 0 && q.Sequence[0].usesState()
+}
+
+// HandleState handles the state of the terminal, matching steps waiting for it
+func (q *QueueStory) HandleState(s State) (in string, err error) {
+	q.state = s
+
+	if len(q.Sequence) == 0 {
+		return "", SkipZeroMatches
+	}
+
+	var step = q.Sequence[0]
+
+	if (!step.stateOnly() && !q.armed) || !q.stateMatcher(step) {
+		return "", SkipWrite
+	}
+
+	return q.next("")
+}
+
+// next shifts the current step, returning its input
+func (q *QueueStory) next(line string) (in string, err error) {
+	q.armed = false
+	var index, now = q.done, q.clock().Now()
 	var step = q.shift()
-	q.pastStepTime = time.Now()
+
+	logger(q.Logger).Info("Step matched",
+		"step", index,
+		"matcher", step.matcherName(),
+		"line", strings.TrimRight(line, "\r\n"),
+		"elapsed", now.Sub(q.pastStepTime))
+
+	q.pastStepTime = now
 
 	if step.SkipWrite {
 		return "", SkipWrite
 	}
 
+	if len(step.Keys) != 0 {
+		keys, err := KeySequence(step.Keys...)
+
+		if err != nil {
+			return "", err
+		}
+
+		return step.Write + keys, WriteRaw
+	}
+
+	if step.Secret {
+		return step.Write, SecretWrite
+	}
+
 	return step.Write, nil
 }
 
-func (q *QueueStory) matcher(in string, step Step) bool {
+func (q *QueueStory) clock() Clock {
+	if q.Clock == nil {
+		return SystemClock
+	}
+
+	return q.Clock
+}
+
+func (q *QueueStory) matcher(in string, stream OutputStream, step Step) bool {
+	if step.Stream != AnyStream && step.Stream != stream {
+		return false
+	}
+
+	if step.stateOnly() || q.armed {
+		return false
+	}
+
 	switch {
 	case step.ReadFunc != nil:
 		return step.ReadFunc(in)
@@ -363,6 +916,7 @@ func (q *QueueStory) shift() Step {
 	if len(q.Sequence) != 0 {
 		step = q.Sequence[0]
 		q.Sequence = q.Sequence[1:]
+		q.done++
 	} else {
 		q.Sequence = []Step{}
 	}
@@ -370,6 +924,74 @@ func (q *QueueStory) shift() Step {
 	return step
 }
 
+func (q *QueueStory) stateMatcher(step Step) bool {
+	return (!step.NoEcho || !q.state.Mode.Echo) &&
+		(!step.InputRequested || q.state.InputRequested) &&
+		(step.Foreground == "" || q.state.Foreground.is(step.Foreground))
+}
+
+// usesState tells if the step matches the terminal state
+func (s Step) usesState() bool {
+	return s.NoEcho || s.InputRequested || s.Foreground != ""
+}
+
+// stateOnly tells if the step only matches the terminal state
+func (s Step) stateOnly() bool {
+	return s.usesState() && s.Read == "" && s.ReadRegex == nil && s.ReadFunc == nil
+}
+
+// matcherName for logging
+func (s Step) matcherName() string {
+	switch {
+	case s.ReadFunc != nil:
+		return "func"
+	case s.ReadRegex != nil:
+		return "regex"
+	case s.Read != "":
+		return "read"
+	default:
+		return "state"
+	}
+}
+
+// description of what the step waits for
+func (s Step) description() string {
+	var conditions []string
+
+	if s.InputRequested {
+		conditions = append(conditions, "input request")
+	}
+
+	if s.NoEcho {
+		conditions = append(conditions, "echo disabled")
+	}
+
+	if s.Foreground != "" {
+		conditions = append(conditions, fmt.Sprintf("%q in the foreground", s.Foreground))
+	}
+
+	if s.stateOnly() {
+		return strings.Join(conditions, " with ")
+	}
+
+	var d string
+
+	switch {
+	case s.ReadFunc != nil:
+		d = "line matching function"
+	case s.ReadRegex != nil:
+		d = fmt.Sprintf("line matching %q", s.ReadRegex)
+	default:
+		d = fmt.Sprintf("line \"%v\"", s.Read)
+	}
+
+	if len(conditions) != 0 {
+		d += " and " + strings.Join(conditions, " with ")
+	}
+
+	return d
+}
+
 func similar(s, ref string) bool {
 	return strings.TrimSpace(s) == strings.TrimSpace(ref)
 }
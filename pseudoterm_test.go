@@ -1,6 +1,7 @@
+//go:build !windows
 // +build !windows
 
-package pseudoterm
+package pseudoterm_test
 
 import (
 	"bytes"
@@ -15,7 +16,8 @@ import (
 	"testing"
 	"time"
 
-	"github.com/kylelemons/godebug/diff"
+	"github.com/henvic/pseudoterm"
+	"github.com/henvic/pseudoterm/pseudotermtest"
 )
 
 type faultyMock struct{}
@@ -42,7 +44,7 @@ func (m *faultyMock) Success() bool {
 
 func TestTerminalWithCat(t *testing.T) {
 	var echoStream = &bytes.Buffer{}
-	var term = &Terminal{
+	var term = &pseudoterm.Terminal{
 		Command:    exec.Command("cat"),
 		EchoStream: echoStream,
 	}
@@ -78,7 +80,7 @@ func TestTerminalWithCat(t *testing.T) {
 		wg.Done()
 	}()
 
-	if _, err := term.Write(EOT); err != nil {
+	if _, err := term.Write(pseudoterm.EOT); err != nil {
 		t.Errorf("Expected no error, got %v instead", err)
 	}
 
@@ -103,20 +105,81 @@ Starting... one
 two
 three
 `
-	assertSimilar(t, log, echoStream.String())
+	pseudotermtest.AssertSimilar(t, log, echoStream.String())
 
-	if !term.processState.Exited() {
+	if !term.Wait().Exited() {
 		t.Errorf("Expected process to have exited")
 	}
 
-	if !term.processState.Success() {
+	if !term.Wait().Success() {
 		t.Errorf("Expected process to have terminated successfully")
 	}
 }
 
+func TestTerminalWithSize(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var term = &pseudoterm.Terminal{
+		Command:    exec.Command("stty", "size"),
+		EchoStream: echoStream,
+		Rows:       24,
+		Cols:       100,
+	}
+
+	if err := term.Start(); err != nil {
+		t.Errorf("Expected no error during start, got %v instead", err)
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	pseudotermtest.AssertSimilar(t, "24 100", echoStream.String())
+
+	if err := term.SetSize(30, 80); err != nil {
+		t.Errorf("Expected no error resizing, got %v instead", err)
+	}
+
+	if term.Rows != 30 || term.Cols != 80 {
+		t.Errorf("Expected size to be 30x80, got %vx%v instead", term.Rows, term.Cols)
+	}
+}
+
+func TestTerminalWithStoryHandlesLinesLeftAfterExit(t *testing.T) {
+	var term = &pseudoterm.Terminal{
+		Command: exec.Command("printf", "one\ntwo\nthree\n"),
+	}
+
+	var story = &pseudoterm.QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(pseudoterm.Step{
+		Read:      "one",
+		SkipWrite: true,
+	},
+		pseudoterm.Step{
+			Read:      "three",
+			SkipWrite: true,
+		})
+
+	if err := term.Start(); err != nil {
+		t.Errorf("Expected no error during start, got %v instead", err)
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	if err := term.Watch(story); err != nil {
+		t.Errorf("Expected no error during watch, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success.")
+	}
+}
+
 func TestTerminalRunWithFaultyMock(t *testing.T) {
 	var echoStream = &bytes.Buffer{}
-	var term = &Terminal{
+	var term = &pseudoterm.Terminal{
 		Command:    exec.Command("mocks/mock.sh"),
 		EchoStream: echoStream,
 	}
@@ -130,24 +193,24 @@ func TestTerminalRunWithFaultyMock(t *testing.T) {
 
 func TestTerminalWithStory(t *testing.T) {
 	var echoStream = &bytes.Buffer{}
-	var term = &Terminal{
+	var term = &pseudoterm.Terminal{
 		Command:    exec.Command("mocks/mock.sh"),
 		EchoStream: echoStream,
 	}
 
-	var story = &QueueStory{
+	var story = &pseudoterm.QueueStory{
 		Timeout: 5 * time.Second,
 	}
 
-	story.Add(Step{
+	story.Add(pseudoterm.Step{
 		Read:      "Starting",
 		SkipWrite: true,
 	},
-		Step{
+		pseudoterm.Step{
 			Read:  "Your name:",
 			Write: "Henrique",
 		},
-		Step{
+		pseudoterm.Step{
 			Read:  "Your age:",
 			Write: "10",
 		})
@@ -163,52 +226,52 @@ Your age: 10
 Your age is 10
 Bye!`
 
-	assertSimilar(t, log, echoStream.String())
+	pseudotermtest.AssertSimilar(t, log, echoStream.String())
 
 	if !story.Success() {
 		t.Errorf("Story didn't success.")
 	}
 
-	if !term.processState.Exited() {
+	if !term.Wait().Exited() {
 		t.Errorf("Expected process to have exited")
 	}
 
-	if !term.processState.Success() {
+	if !term.Wait().Success() {
 		t.Errorf("Expected process to have terminated successfully")
 	}
 }
 
 func TestTerminalWithComplexStory(t *testing.T) {
 	var echoStream = &bytes.Buffer{}
-	var term = &Terminal{
+	var term = &pseudoterm.Terminal{
 		Command:    exec.Command("mocks/mock-complex.sh"),
 		EchoStream: echoStream,
 	}
 
-	var story = &QueueStory{
+	var story = &pseudoterm.QueueStory{
 		Timeout: 5 * time.Second,
 	}
 
 	var numFromStep string
 
 	story.Add(
-		Step{
+		pseudoterm.Step{
 			Read:      "Starting",
 			SkipWrite: true,
 		},
-		Step{
+		pseudoterm.Step{
 			Read:  "Your name:",
 			Write: "Henrique",
 		},
-		Step{
+		pseudoterm.Step{
 			Read:  "Your age:",
 			Write: "10",
 		},
-		Step{
+		pseudoterm.Step{
 			ReadRegex: regexp.MustCompile("p([a-z]+)ch"),
 			Write:     "ok",
 		},
-		Step{
+		pseudoterm.Step{
 			ReadFunc: func(in string) bool {
 				numFromStep = strings.TrimPrefix(in, "Random: ")
 				return strings.HasPrefix(in, "Random: ")
@@ -231,36 +294,36 @@ Random: ` + numFromStep + `ack
 num: ack
 Bye!`
 
-	assertSimilar(t, log, echoStream.String())
+	pseudotermtest.AssertSimilar(t, log, echoStream.String())
 
 	if !story.Success() {
 		t.Errorf("Story didn't success.")
 	}
 
-	if !term.processState.Exited() {
+	if !term.Wait().Exited() {
 		t.Errorf("Expected process to have exited")
 	}
 
-	if !term.processState.Success() {
+	if !term.Wait().Success() {
 		t.Errorf("Expected process to have terminated successfully")
 	}
 }
 
 func TestTerminalWithComplexStoryPrecedence(t *testing.T) {
 	var echoStream = &bytes.Buffer{}
-	var term = &Terminal{
+	var term = &pseudoterm.Terminal{
 		Command:    exec.Command("mocks/mock-precedence-test.sh"),
 		EchoStream: echoStream,
 	}
 
-	var story = &QueueStory{
+	var story = &pseudoterm.QueueStory{
 		Timeout: 5 * time.Second,
 	}
 
 	var numFromStep string
 
 	story.Add(
-		Step{
+		pseudoterm.Step{
 			Read:      "Skip",
 			ReadRegex: regexp.MustCompile("^[0-9]+$"),
 			ReadFunc: func(in string) bool {
@@ -268,23 +331,23 @@ func TestTerminalWithComplexStoryPrecedence(t *testing.T) {
 			},
 			SkipWrite: true,
 		},
-		Step{
+		pseudoterm.Step{
 			Read:      "Skip",
 			ReadRegex: regexp.MustCompile("[a-z]:"),
 			Write:     "Henrique",
 		},
-		Step{
+		pseudoterm.Step{
 			ReadRegex: regexp.MustCompile("^[a-d]+$"),
 			ReadFunc: func(in string) bool {
 				return strings.Contains(in, "Your age:")
 			},
 			Write: "10",
 		},
-		Step{
+		pseudoterm.Step{
 			ReadRegex: regexp.MustCompile("p([a-z]+)ch"),
 			Write:     "ok",
 		},
-		Step{
+		pseudoterm.Step{
 			Read: "Skip",
 			ReadFunc: func(in string) bool {
 				numFromStep = strings.TrimPrefix(in, "Random: ")
@@ -308,41 +371,41 @@ Random: ` + numFromStep + `ack
 num: ack
 Bye!`
 
-	assertSimilar(t, log, echoStream.String())
+	pseudotermtest.AssertSimilar(t, log, echoStream.String())
 
 	if !story.Success() {
 		t.Errorf("Story didn't success.")
 	}
 
-	if !term.processState.Exited() {
+	if !term.Wait().Exited() {
 		t.Errorf("Expected process to have exited")
 	}
 
-	if !term.processState.Success() {
+	if !term.Wait().Success() {
 		t.Errorf("Expected process to have terminated successfully")
 	}
 }
 
 func TestTerminalWithStoryShouldNotBlock(t *testing.T) {
 	var echoStream = &bytes.Buffer{}
-	var term = &Terminal{
+	var term = &pseudoterm.Terminal{
 		Command:    exec.Command("mocks/mock-mixed.sh"),
 		EchoStream: echoStream,
 	}
 
-	var story = &QueueStory{
+	var story = &pseudoterm.QueueStory{
 		Timeout: 6 * time.Second,
 	}
 
-	story.Add(Step{
+	story.Add(pseudoterm.Step{
 		Read:      "Starting",
 		SkipWrite: true,
 	},
-		Step{
+		pseudoterm.Step{
 			Read:  "Your name:",
 			Write: "Henrique",
 		},
-		Step{
+		pseudoterm.Step{
 			Read:  "Your age:",
 			Write: "10",
 		})
@@ -389,74 +452,39 @@ Your age is 10
 Avoid killing itself? [no]: yes
 Bye!`
 
-	assertSimilar(t, log, echoStream.String())
+	pseudotermtest.AssertSimilar(t, log, echoStream.String())
 
 	if !story.Success() {
 		t.Errorf("Story didn't success.")
 	}
 
-	if !term.processState.Exited() {
+	if !term.Wait().Exited() {
 		t.Errorf("Expected process to have exited")
 	}
 
-	if !term.processState.Success() {
+	if !term.Wait().Success() {
 		t.Errorf("Expected process to have terminated successfully")
 	}
 }
 
-func TestTerminalWithAlreadyStartedStory(t *testing.T) {
-	var echoStream = &bytes.Buffer{}
-	var term = &Terminal{
-		Command:    exec.Command("mocks/mock.sh"),
-		EchoStream: echoStream,
-	}
-
-	var story = &QueueStory{
-		Timeout: 5 * time.Second,
-	}
-
-	story.Add(Step{
-		Read:      "Starting",
-		SkipWrite: true,
-	},
-		Step{
-			Read:  "Your name:",
-			Write: "Henrique",
-		},
-		Step{
-			Read:  "Your age:",
-			Write: "10",
-		})
-
-	_, _ = story.Setup()
-
-	if err := term.Start(); err != nil {
-		t.Errorf("Expected no error during start, got %v instead", err)
-	}
-
-	if err := term.Watch(story); err != errAlreadyInitialized {
-		t.Errorf("Expected error %v during run, got %v instead", errAlreadyInitialized, err)
-	}
-}
-
 func TestTerminalWithStoryAndNoOutput(t *testing.T) {
-	var term = &Terminal{
+	var term = &pseudoterm.Terminal{
 		Command: exec.Command("mocks/mock.sh"),
 	}
 
-	var story = &QueueStory{
+	var story = &pseudoterm.QueueStory{
 		Timeout: 5 * time.Second,
 	}
 
-	story.Add(Step{
+	story.Add(pseudoterm.Step{
 		Read:      "Starting",
 		SkipWrite: true,
 	},
-		Step{
+		pseudoterm.Step{
 			Read:  "Your name:",
 			Write: "Henrique",
 		},
-		Step{
+		pseudoterm.Step{
 			Read:  "Your age:",
 			Write: "10",
 		})
@@ -476,12 +504,12 @@ func TestTerminalWithStoryAndNoOutput(t *testing.T) {
 
 func TestTerminalWithReadOnlyStory(t *testing.T) {
 	var echoStream = &bytes.Buffer{}
-	var term = &Terminal{
+	var term = &pseudoterm.Terminal{
 		Command:    exec.Command("mocks/read-only-mock.sh"),
 		EchoStream: echoStream,
 	}
 
-	var story = &QueueStory{
+	var story = &pseudoterm.QueueStory{
 		Timeout: 5 * time.Second,
 	}
 
@@ -493,7 +521,7 @@ func TestTerminalWithReadOnlyStory(t *testing.T) {
 Wait...
 Bye!`
 
-	assertSimilar(t, log, echoStream.String())
+	pseudotermtest.AssertSimilar(t, log, echoStream.String())
 
 	if !story.Success() {
 		t.Errorf("Story didn't success.")
@@ -502,24 +530,24 @@ Bye!`
 
 func TestTerminalWithStoryTimeout(t *testing.T) {
 	var echoStream = &bytes.Buffer{}
-	var term = &Terminal{
+	var term = &pseudoterm.Terminal{
 		Command:    exec.Command("mocks/mock-timeout.sh"),
 		EchoStream: echoStream,
 	}
 
-	var story = &QueueStory{
+	var story = &pseudoterm.QueueStory{
 		Timeout: 100 * time.Millisecond,
 	}
 
-	story.Add(Step{
+	story.Add(pseudoterm.Step{
 		Read:      "Starting",
 		SkipWrite: true,
 	},
-		Step{
+		pseudoterm.Step{
 			Read:  "Your name:",
 			Write: "Henrique",
 		},
-		Step{
+		pseudoterm.Step{
 			Read:  "Your age:",
 			Write: "10",
 		})
@@ -527,7 +555,7 @@ func TestTerminalWithStoryTimeout(t *testing.T) {
 	err := term.Run(story)
 
 	switch err.(type) {
-	case ExecutionError:
+	case pseudoterm.ExecutionError:
 		var wantErr = "Run error: context deadline exceeded"
 		if err.Error() != wantErr {
 			t.Errorf("Wanted error to be %v, got %v instead", wantErr, err)
@@ -541,10 +569,10 @@ Your name: Henrique
 Your name is Henrique
 `
 
-	assertSimilar(t, log, echoStream.String())
+	pseudotermtest.AssertSimilar(t, log, echoStream.String())
 
-	var sequenceMissing = []Step{
-		Step{
+	var sequenceMissing = []pseudoterm.Step{
+		pseudoterm.Step{
 			Read:  "Your age:",
 			Write: "10",
 		},
@@ -570,24 +598,24 @@ Your name is Henrique
 
 func TestTerminalWithStepTimeout(t *testing.T) {
 	var echoStream = &bytes.Buffer{}
-	var term = &Terminal{
+	var term = &pseudoterm.Terminal{
 		Command:    exec.Command("mocks/mock-timeout.sh"),
 		EchoStream: echoStream,
 	}
 
-	var story = &QueueStory{
+	var story = &pseudoterm.QueueStory{
 		Timeout: 5 * time.Second,
 	}
 
-	story.Add(Step{
+	story.Add(pseudoterm.Step{
 		Read:      "Starting",
 		SkipWrite: true,
 	},
-		Step{
+		pseudoterm.Step{
 			Read:  "Your name:",
 			Write: "Henrique",
 		},
-		Step{
+		pseudoterm.Step{
 			Read:    "Your age:",
 			Write:   "10",
 			Timeout: 1 * time.Millisecond,
@@ -605,7 +633,7 @@ Your name: Henrique
 Your name is Henrique
 `
 
-	assertSimilar(t, log, echoStream.String())
+	pseudotermtest.AssertSimilar(t, log, echoStream.String())
 
 	if len(story.Sequence) != 1 || story.Sequence[0].Read != "Your age:" {
 		t.Errorf("Expected story sequence to contain missing sequence, got %+v instead", story.Sequence)
@@ -624,116 +652,13 @@ Your name is Henrique
 	}
 }
 
-func TestStoryTimeout(t *testing.T) {
-	var story = &QueueStory{
-		Timeout: 10 * time.Millisecond,
-	}
-
-	var sequence = []Step{
-		Step{
-			Read:  "Select from 1..2:",
-			Write: "2",
-		},
-		Step{
-			Read:    "Project:",
-			Write:   "test",
-			Timeout: time.Second,
-		},
-	}
-
-	story.Add(sequence...)
-
-	var ctx, err = story.Setup()
-
-	if err != nil {
-		t.Errorf("Expected story setup to be fine, got %v error instead", err)
-	}
-
-	if !reflect.DeepEqual(story.Sequence, sequence) {
-		t.Errorf("Expected story sequence to be equal passed value")
-	}
-
-	time.Sleep(20 * time.Millisecond)
-
-	select {
-	case <-ctx.Done():
-		if ctx.Err() != context.DeadlineExceeded {
-			t.Errorf("Expected context error to be %v, got %v instead",
-				context.DeadlineExceeded,
-				ctx.Err())
-		}
-	default:
-		t.Errorf("Expected context error due to story timeout, got %v instead", ctx.Err())
-	}
-
-	if _, err := story.Setup(); err != errAlreadyInitialized {
-		t.Errorf("Wanted multiple initialization error to be %v, got %v instead",
-			errAlreadyInitialized,
-			err)
-	}
-}
-
-func TestStepTimeout(t *testing.T) {
-	var story = &QueueStory{}
-
-	var sequence = []Step{
-		Step{
-			Read:    "Select from 1..2:",
-			Write:   "2",
-			Timeout: 10 * time.Millisecond,
-		},
-		Step{
-			Read:  "Project:",
-			Write: "test",
-		},
-	}
-
-	story.Add(sequence...)
-
-	var _, err = story.Setup()
-
-	if err != nil {
-		t.Errorf("Expected story setup to be fine, got %v error instead", err)
-	}
-
-	if !reflect.DeepEqual(story.Sequence, sequence) {
-		t.Errorf("Expected story sequence to be equal passed value")
-	}
-
-	time.Sleep(20 * time.Millisecond)
-
-	var wantErr = `Timed out while waiting for line "Select from 1..2:": timeout 10ms`
-
-	if err := story.TickHandler(); err == nil ||
-		err.Error() != wantErr {
-		t.Errorf("Wanted err to be %v, got %v instead", wantErr, err)
-	}
-
-	select {
-	case <-story.ctx.Done():
-		if story.ctx.Err() != context.DeadlineExceeded {
-			t.Errorf("Expected context error to be %v, got %v instead",
-				context.DeadlineExceeded,
-				story.ctx.Err())
-		}
-	default:
-		t.Errorf("Expected context error due to timeout, got %v instead", story.ctx.Err())
-	}
-
-	if _, err := story.Setup(); err != errAlreadyInitialized {
-		t.Errorf("Wanted multiple initialization error to be %v, got %v instead",
-			errAlreadyInitialized,
-			err)
-	}
-}
-
 func TestStoryCancel(t *testing.T) {
-	var story = &QueueStory{
+	var story = &pseudoterm.QueueStory{
 		Timeout: 10 * time.Millisecond,
 	}
 
-	var sequence = []Step{
-		Step{
+	var sequence = []pseudoterm.Step{
+		pseudoterm.Step{
 			Read:  "Select from 1..2:",
 			Write: "2",
 		},
@@ -766,12 +691,12 @@ func TestStoryCancel(t *testing.T) {
 }
 
 func TestStoryHandleLineAndTeardown(t *testing.T) {
-	var story = &QueueStory{
-		Timeout: 4 * LineReaderInterval,
+	var story = &pseudoterm.QueueStory{
+		Timeout: 4 * pseudoterm.LineReaderInterval,
 	}
 
-	var sequence = []Step{
-		Step{
+	var sequence = []pseudoterm.Step{
+		pseudoterm.Step{
 			Read:  "Select from 1..2:",
 			Write: "2",
 		},
@@ -797,7 +722,7 @@ func TestStoryHandleLineAndTeardown(t *testing.T) {
 		t.Errorf("Expected values doens't match: (%v, %v)", in, err)
 	}
 
-	time.Sleep(LineReaderInterval)
+	time.Sleep(pseudoterm.LineReaderInterval)
 
 	if err := story.TickHandler(); err != nil {
 		t.Errorf("Expected no error, got %v instead", err)
@@ -820,72 +745,3 @@ func TestStoryHandleLineAndTeardown(t *testing.T) {
 		t.Errorf("Story didn't success.")
 	}
 }
-
-func TestStoryAddAndInternalShift(t *testing.T) {
-	var story = &QueueStory{}
-	var addStep = Step{
-		Read: "foo",
-	}
-
-	if _, err := story.Setup(); err != nil {
-		t.Errorf("Error trying to setup story: %v", err)
-	}
-
-	story.Add(addStep)
-
-	var step = story.shift()
-
-	if len(story.Sequence) != 0 {
-		t.Errorf("Expected sequence to have length 0, got %v instead", len(story.Sequence))
-	}
-
-	if step.Read != "foo" {
-		t.Errorf("Wrong value on step")
-	}
-
-	var stepDummy = story.shift()
-
-	if stepDummy.Read != "" {
-		t.Errorf("Expected step to be dummy, got %+v instead", stepDummy)
-	}
-
-	if len(story.Sequence) != 0 {
-		t.Errorf("Expected sequence to have length 0, got %v instead", len(story.Sequence))
-	}
-
-	if !story.Success() {
-		t.Errorf("Story didn't success.")
-	}
-}
-
-func assertSimilar(t *testing.T, want string, got string) {
-	if w, g := normalize(want), normalize(got); w != g {
-		t.Errorf(
-			"Strings doesn't match after normalization:\n%s",
-			diff.Diff(w, g))
-	}
-}
-
-// Normalize string breaking lines with \n and removing extra spacing
-// on the beginning and end of strings
-func normalize(s string) string {
-	s = strings.Replace(s, "^D", "", -1)
-	var parts = strings.Split(s, "\n")
-	var final = make([]string, 10*len(parts))
-
-	var c = 0
-
-	for p := range parts {
-		var tp = strings.TrimSpace(parts[p])
-
-		if tp != "" {
-			final[c] = "\n"
-			c++
-		}
-
-		final[c] = tp
-		c++
-	}
-
-	return strings.TrimSpace(strings.Join(final, ""))
-}
@@ -0,0 +1,275 @@
+/*
+Package pseudotermtest runs pseudoterm stories inside Go tests
+and compares their output against golden files.
+
+	func TestLogin(t *testing.T) {
+		var story = &pseudoterm.QueueStory{Timeout: 5 * time.Second}
+		story.Add(pseudoterm.Step{Read: "Password:", Write: "secret"})
+
+		var res = pseudotermtest.Run(t, &pseudoterm.Terminal{
+			Command: exec.Command("./login"),
+		}, story)
+
+		pseudotermtest.Golden(t, "login", res.Output)
+	}
+
+Run the tests with -update to create or update the golden files.
+*/
+package pseudotermtest
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+	"github.com/kylelemons/godebug/diff"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// Wildcard on golden files matches any text on a line
+const Wildcard = "{{*}}"
+
+// StopTimeout is how long Run waits for the program to end after
+// the story is over before killing it
+var StopTimeout = 5 * time.Second
+
+// Result of running a story.
+// Stderr is only set when using Terminal SeparateStderr.
+type Result struct {
+	Output   string
+	Stderr   string
+	ExitCode int
+}
+
+// Run the story on the terminal, failing the test if the story doesn't succeed.
+// Stories implementing Success() bool (like QueueStory) are checked for success.
+// The output of the program is logged when the test fails.
+func Run(t testing.TB, term *pseudoterm.Terminal, story pseudoterm.Story) *Result {
+	t.Helper()
+
+	var out = &Buffer{}
+
+	if term.EchoStream != nil {
+		term.EchoStream = io.MultiWriter(out, term.EchoStream)
+	} else {
+		term.EchoStream = out
+	}
+
+	var stderr = &Buffer{}
+
+	if term.SeparateStderr {
+		if term.StderrEchoStream != nil {
+			term.StderrEchoStream = io.MultiWriter(stderr, term.StderrEchoStream)
+		} else {
+			term.StderrEchoStream = stderr
+		}
+	}
+
+	var ended = make(chan struct{})
+	var res = &Result{}
+
+	t.Cleanup(func() {
+		select {
+		case <-ended:
+		default:
+			kill(term)
+		}
+	})
+
+	var err = term.Run(story)
+
+	if term.OutputDone() != nil {
+		res.ExitCode = wait(term)
+		close(ended)
+		<-term.OutputDone()
+	}
+
+	res.Output = out.String()
+	res.Stderr = stderr.String()
+
+	var failed = err != nil
+
+	if err != nil {
+		t.Errorf("Expected no error running story, got %v instead", err)
+	}
+
+	if s, ok := story.(interface{ Success() bool }); ok && !s.Success() && err == nil {
+		failed = true
+		t.Errorf("Story didn't succeed")
+	}
+
+	if failed && res.Stderr != "" {
+		t.Logf("Output:\n%s\nStderr:\n%s", res.Output, res.Stderr)
+	} else if failed {
+		t.Logf("Output:\n%s", res.Output)
+	}
+
+	return res
+}
+
+// wait for the program to end, killing it if it takes too long
+func wait(term *pseudoterm.Terminal) int {
+	var done = make(chan struct{})
+
+	go func() {
+		term.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(StopTimeout):
+		kill(term)
+		<-done
+	}
+
+	return term.ExitCode()
+}
+
+// kill the process, or stop the terminal if it doesn't run a local process
+func kill(term *pseudoterm.Terminal) {
+	if term.Command != nil && term.Command.Process != nil {
+		_ = term.Command.Process.Kill()
+		return
+	}
+
+	_ = term.Stop()
+}
+
+// Golden compares the normalized output against the golden file testdata/<name>.golden.
+// Lines of the golden file can contain the Wildcard placeholder to match volatile text.
+// When running the tests with -update the golden file is written instead,
+// keeping the lines that still match.
+func Golden(t testing.TB, name string, got string) {
+	t.Helper()
+
+	var filename = filepath.Join("testdata", name+".golden")
+	var want, err = os.ReadFile(filename)
+
+	if err != nil && !(*update && os.IsNotExist(err)) {
+		t.Fatalf("Can't read golden file: %v", err)
+	}
+
+	if *update {
+		var content = merge(Normalize(string(want)), Normalize(got)) + "\n"
+
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatalf("Can't create testdata directory: %v", err)
+		}
+
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatalf("Can't update golden file: %v", err)
+		}
+
+		return
+	}
+
+	var w, g = Normalize(string(want)), Normalize(got)
+
+	if !Match(w, g) {
+		t.Errorf("Output doesn't match golden file %v:\n%s", filename, diff.Diff(w, g))
+	}
+}
+
+// AssertSimilar fails the test if the strings don't match after normalization
+func AssertSimilar(t testing.TB, want string, got string) {
+	t.Helper()
+
+	if w, g := Normalize(want), Normalize(got); w != g {
+		t.Errorf(
+			"Strings doesn't match after normalization:\n%s",
+			diff.Diff(w, g))
+	}
+}
+
+// Normalize string breaking lines with \n and removing extra spacing
+// on the beginning and end of strings and empty lines
+func Normalize(s string) string {
+	s = strings.Replace(s, "^D", "", -1)
+	var lines []string
+
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Match tells if normalized output matches a normalized golden content with wildcards
+func Match(golden, got string) bool {
+	var gl, ol = strings.Split(golden, "\n"), strings.Split(got, "\n")
+
+	if len(gl) != len(ol) {
+		return false
+	}
+
+	for c := range gl {
+		if !matchLine(gl[c], ol[c]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchLine(golden, got string) bool {
+	if !strings.Contains(golden, Wildcard) {
+		return golden == got
+	}
+
+	var parts = strings.Split(golden, Wildcard)
+
+	for c := range parts {
+		parts[c] = regexp.QuoteMeta(parts[c])
+	}
+
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$").MatchString(got)
+}
+
+// merge keeps the golden lines matching the output, so wildcards aren't lost on updates
+func merge(golden, got string) string {
+	var gl, ol = strings.Split(golden, "\n"), strings.Split(got, "\n")
+
+	if len(gl) != len(ol) {
+		return got
+	}
+
+	for c := range ol {
+		if matchLine(gl[c], ol[c]) {
+			ol[c] = gl[c]
+		}
+	}
+
+	return strings.Join(ol, "\n")
+}
+
+// Buffer is a bytes.Buffer safe for concurrent use,
+// so you can read the output while the program writes to it
+type Buffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *Buffer) Write(p []byte) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+// String returns the contents of the buffer
+func (s *Buffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
@@ -0,0 +1,211 @@
+//go:build !windows
+// +build !windows
+
+package pseudotermtest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+)
+
+// fakeT records failures instead of failing the test
+type fakeT struct {
+	testing.TB
+	errors []string
+	logs   []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Fatalf(format string, args ...interface{}) {
+	f.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func (f *fakeT) Logf(format string, args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+
+func complexStory() *pseudoterm.QueueStory {
+	var story = &pseudoterm.QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(
+		pseudoterm.Step{
+			Read:  "Your name:",
+			Write: "Henrique",
+		},
+		pseudoterm.Step{
+			Read:  "Your age:",
+			Write: "10",
+		},
+		pseudoterm.Step{
+			ReadRegex: regexp.MustCompile("p([a-z]+)ch"),
+			Write:     "ok",
+		},
+		pseudoterm.Step{
+			ReadRegex: regexp.MustCompile("^Random: "),
+			Write:     "ack",
+		})
+
+	return story
+}
+
+func TestRunAndGolden(t *testing.T) {
+	var res = Run(t, &pseudoterm.Terminal{
+		Command: exec.Command("../mocks/mock-complex.sh"),
+	}, complexStory())
+
+	if res.ExitCode != 0 {
+		t.Errorf("Expected exit code 0, got %v instead", res.ExitCode)
+	}
+
+	Golden(t, "complex", res.Output)
+}
+
+func TestRunFailure(t *testing.T) {
+	var ft = &fakeT{TB: t}
+	var story = &pseudoterm.QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(pseudoterm.Step{
+		Read:  "Your name:",
+		Write: "Henrique",
+	},
+		pseudoterm.Step{
+			Read:  "Your favorite color:",
+			Write: "blue",
+		})
+
+	var res = Run(ft, &pseudoterm.Terminal{
+		Command: exec.Command("../mocks/read-only-mock.sh"),
+	}, story)
+
+	if len(ft.errors) != 1 || ft.errors[0] != "Story didn't succeed" {
+		t.Errorf("Expected story failure, got %v instead", ft.errors)
+	}
+
+	if len(ft.logs) != 1 || !strings.Contains(ft.logs[0], "Wait...") {
+		t.Errorf("Expected output to be logged, got %v instead", ft.logs)
+	}
+
+	AssertSimilar(t, "Hi!\nWait...\nBye!", res.Output)
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	var ft = &fakeT{TB: t}
+
+	Golden(ft, "complex", "Starting\nYour name: Henrique")
+
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "Output doesn't match golden file testdata/complex.golden") {
+		t.Errorf("Expected golden mismatch, got %v instead", ft.errors)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	var cases = []struct {
+		golden string
+		got    string
+		match  bool
+	}{
+		{"a\nb", "a\nb", true},
+		{"a\nb", "a\nc", false},
+		{"a\nb", "a", false},
+		{"Random: {{*}}: ack", "Random: 1234: ack", true},
+		{"Random: {{*}}: ack", "Random: 1234: nack", false},
+		{"{{*}} (1.2s)", "PASS (1.2s)", true},
+		{"a.c", "abc", false},
+	}
+
+	for _, c := range cases {
+		if m := Match(c.golden, c.got); m != c.match {
+			t.Errorf("Expected Match(%q, %q) to be %v", c.golden, c.got, c.match)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	var got = merge("Random: {{*}}\nnum: ack", "Random: 42\nnum: nack")
+
+	if want := "Random: {{*}}\nnum: nack"; got != want {
+		t.Errorf("Expected merge to keep wildcards, got %q instead", got)
+	}
+
+	if got := merge("a", "b\nc"); got != "b\nc" {
+		t.Errorf("Expected merge to use output when lines differ, got %q instead", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := Normalize("  one\r\n\r\n two ^D\n\n"); got != "one\ntwo" {
+		t.Errorf("Unexpected normalized string %q", got)
+	}
+}
+
+func TestRunWithProgram(t *testing.T) {
+	var story = &pseudoterm.QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(pseudoterm.Step{
+		Read:  "Your name:",
+		Write: "Henrique",
+	})
+
+	var res = Run(t, &pseudoterm.Terminal{
+		Transport: &pseudoterm.Program{
+			Echo: true,
+			Func: func(stdin io.Reader, stdout io.Writer) int {
+				fmt.Fprint(stdout, "Your name: ")
+				var name, _ = bufio.NewReader(stdin).ReadString('\n')
+				fmt.Fprintf(stdout, "Hi, %s", name)
+				return 1
+			},
+		},
+	}, story)
+
+	if res.ExitCode != 1 {
+		t.Errorf("Expected exit code 1, got %v instead", res.ExitCode)
+	}
+
+	AssertSimilar(t, "Your name: Henrique\nHi, Henrique", res.Output)
+}
+
+func TestRunWithSeparateStderr(t *testing.T) {
+	var story = &pseudoterm.QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(pseudoterm.Step{
+		Read:   "Your name:",
+		Stream: pseudoterm.StdoutStream,
+		Write:  "Henrique",
+	})
+
+	var res = Run(t, &pseudoterm.Terminal{
+		Command:        exec.Command("../mocks/mock-stderr.sh"),
+		SeparateStderr: true,
+	}, story)
+
+	if res.ExitCode != 1 {
+		t.Errorf("Expected exit code 1, got %v instead", res.ExitCode)
+	}
+
+	AssertSimilar(t, "Starting\nYour name: Henrique\nHi Henrique", res.Output)
+	AssertSimilar(t, "Warning: no config\nError: Henrique not found", res.Stderr)
+}
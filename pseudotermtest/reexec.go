@@ -0,0 +1,92 @@
+package pseudotermtest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+)
+
+// MainEnv is the environment variable telling the test binary
+// which registered main function to run instead of the tests
+const MainEnv = "PSEUDOTERMTEST_MAIN"
+
+var (
+	mains   = map[string]func() int{}
+	mainsMu sync.RWMutex
+)
+
+// Register a main function under a name, so Command can run it as a child process.
+// The function returns the exit code of the program.
+// Register it on an init function or on TestMain before calling Main.
+func Register(name string, main func() int) {
+	mainsMu.Lock()
+	defer mainsMu.Unlock()
+
+	if _, ok := mains[name]; ok {
+		panic("pseudotermtest: main function " + name + " already registered")
+	}
+
+	mains[name] = main
+}
+
+// Main runs the registered main function when the test binary is executed by Command
+// and the tests otherwise. Call it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		pseudotermtest.Register("cli", cli.Main)
+//		pseudotermtest.Main(m)
+//	}
+func Main(m *testing.M) {
+	var name, ok = os.LookupEnv(MainEnv)
+
+	if !ok {
+		os.Exit(m.Run())
+	}
+
+	mainsMu.RLock()
+	var main = mains[name]
+	mainsMu.RUnlock()
+
+	if main == nil {
+		fmt.Fprintf(os.Stderr, "pseudotermtest: main function %q not registered\n", name)
+		os.Exit(2)
+	}
+
+	os.Exit(main())
+}
+
+// Command to run the registered main function on a child process of the test binary.
+// GOCOVERDIR is set to the directory used by go test -cover,
+// so the coverage data of the child is collected when it exits.
+func Command(t testing.TB, name string, args ...string) *exec.Cmd {
+	t.Helper()
+
+	mainsMu.RLock()
+	var _, ok = mains[name]
+	mainsMu.RUnlock()
+
+	if !ok {
+		t.Fatalf("Main function %q not registered", name)
+	}
+
+	var cmd = exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), MainEnv+"="+name)
+
+	if dir := coverDir(); dir != "" {
+		cmd.Env = append(cmd.Env, "GOCOVERDIR="+dir)
+	}
+
+	return cmd
+}
+
+// coverDir returns the directory where go test collects coverage data, if any
+func coverDir() string {
+	if f := flag.Lookup("test.gocoverdir"); f != nil && f.Value.String() != "" {
+		return f.Value.String()
+	}
+
+	return os.Getenv("GOCOVERDIR")
+}
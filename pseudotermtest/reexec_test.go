@@ -0,0 +1,70 @@
+//go:build !windows
+// +build !windows
+
+package pseudotermtest
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+)
+
+func greeter() int {
+	fmt.Print("Your name: ")
+	var name, err = bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if err != nil {
+		fmt.Println("can't read name:", err)
+		return 1
+	}
+
+	fmt.Printf("Hello, %s%s!\n", strings.TrimSpace(name), strings.Join(os.Args[1:], ""))
+	return 3
+}
+
+func TestMain(m *testing.M) {
+	Register("greeter", greeter)
+	Main(m)
+}
+
+func TestCommand(t *testing.T) {
+	var story = &pseudoterm.QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(pseudoterm.Step{
+		Read:  "Your name:",
+		Write: "Henrique",
+	})
+
+	var res = Run(t, &pseudoterm.Terminal{
+		Command: Command(t, "greeter", "!", "!"),
+	}, story)
+
+	if res.ExitCode != 3 {
+		t.Errorf("Expected exit code 3, got %v instead", res.ExitCode)
+	}
+
+	AssertSimilar(t, "Your name: Henrique\nHello, Henrique!!!", res.Output)
+}
+
+func TestCommandNotRegistered(t *testing.T) {
+	var ft = &fakeT{TB: t}
+	var done = make(chan struct{})
+
+	go func() {
+		defer close(done)
+		Command(ft, "unknown")
+	}()
+
+	<-done
+
+	if len(ft.errors) != 1 || ft.errors[0] != `Main function "unknown" not registered` {
+		t.Errorf("Expected error for unknown main function, got %v instead", ft.errors)
+	}
+}
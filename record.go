@@ -0,0 +1,256 @@
+package pseudoterm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecorderFlushTimeout is how long the Recorder waits for the remaining
+// output of a program to be copied after it exits
+var RecorderFlushTimeout = time.Second
+
+// Recorder lets a human interact with a program running on a Terminal
+// and keeps a Transcript of the session, so it can be turned into a story
+type Recorder struct {
+	Terminal *Terminal
+	Stdin    io.Reader
+	Stdout   io.Writer
+
+	mu          sync.Mutex
+	chunk       bytes.Buffer
+	pendingEcho []byte
+	transcript  *Transcript
+}
+
+// Transcript of an interactive session
+type Transcript struct {
+	Entries  []Entry `json:"entries"`
+	Tail     string  `json:"tail,omitempty"`
+	ExitCode int     `json:"exit_code"`
+}
+
+// Entry of a Transcript: what the program printed and
+// the input line typed in response to it.
+// Lines typed while the echo is disabled are secret and their Input isn't recorded.
+type Entry struct {
+	Output string `json:"output"`
+	Input  string `json:"input"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// Record starts the program on the Terminal, forwards lines typed on Stdin to it
+// and copies its output to Stdout until it ends. The Terminal uses SkipBuffer.
+// Stdin is read on a separate goroutine that might outlive Record, as reads can't be canceled.
+func (r *Recorder) Record() (*Transcript, error) {
+	var t = r.Terminal
+	var stdin, stdout = r.Stdin, r.Stdout
+
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	r.transcript = &Transcript{}
+
+	var out io.Writer = &recorderOutput{r: r, w: stdout}
+
+	if t.EchoStream != nil {
+		out = io.MultiWriter(out, t.EchoStream)
+	}
+
+	defer func(echo io.Writer, skip bool) {
+		t.EchoStream, t.SkipBuffer = echo, skip
+	}(t.EchoStream, t.SkipBuffer)
+
+	t.EchoStream = out
+	t.SkipBuffer = true
+
+	if err := t.Start(); err != nil {
+		return nil, err
+	}
+
+	go r.forward(stdin)
+
+	t.Wait()
+
+	select {
+	case <-t.OutputDone():
+	case <-time.After(RecorderFlushTimeout):
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.transcript.Tail = r.chunk.String()
+	r.chunk.Reset()
+
+	r.transcript.ExitCode = t.ExitCode()
+
+	return r.transcript, nil
+}
+
+func (r *Recorder) forward(stdin io.Reader) {
+	var br = bufio.NewReader(stdin)
+
+	for {
+		line, err := br.ReadString('\n')
+
+		if len(line) != 0 || err == nil {
+			if r.input(strings.TrimRight(line, "\r\n")) != nil {
+				return
+			}
+		}
+
+		if err != nil {
+			// we don't care if writing EOT fails: the program might have ended already
+			_, _ = r.Terminal.Write(EOT)
+			return
+		}
+	}
+}
+
+func (r *Recorder) input(line string) error {
+	var s, err = r.Terminal.State()
+	var secret = err == nil && !s.Mode.Echo
+
+	r.mu.Lock()
+
+	var e = Entry{
+		Output: r.chunk.String(),
+		Input:  line,
+		Secret: secret,
+	}
+
+	if secret {
+		e.Input = ""
+	}
+
+	r.transcript.Entries = append(r.transcript.Entries, e)
+	r.chunk.Reset()
+
+	if !secret {
+		// the tty echoes the line back with a carriage return
+		r.pendingEcho = []byte(line + "\r\n")
+	}
+
+	r.mu.Unlock()
+
+	if secret {
+		_, err = r.Terminal.WriteSecret(line)
+		return err
+	}
+
+	_, err = r.Terminal.WriteLine(line)
+	return err
+}
+
+// recorderOutput copies the program output to the chunk of the next entry
+// and to the user, dropping the echo of the last input line
+// (as the user's own terminal already shows it)
+type recorderOutput struct {
+	r *Recorder
+	w io.Writer
+}
+
+func (o *recorderOutput) Write(p []byte) (n int, err error) {
+	var r = o.r
+	var q = p
+
+	r.mu.Lock()
+
+	for len(q) != 0 && len(r.pendingEcho) != 0 {
+		if q[0] != r.pendingEcho[0] {
+			r.pendingEcho = nil
+			break
+		}
+
+		q = q[1:]
+		r.pendingEcho = r.pendingEcho[1:]
+	}
+
+	r.chunk.Write(q)
+	r.mu.Unlock()
+
+	if _, err = o.w.Write(q); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Prompt is the last non-empty line printed before the input
+func (e Entry) Prompt() string {
+	var lines = strings.Split(strings.Replace(e.Output, "\r", "", -1), "\n")
+
+	for c := len(lines) - 1; c >= 0; c-- {
+		if l := strings.TrimSpace(lines[c]); l != "" {
+			return l
+		}
+	}
+
+	return ""
+}
+
+// Steps inferred from the transcript: one per input line, reading its prompt.
+// Secret entries wait for the echo to be disabled and write a secret.
+func (tr *Transcript) Steps() []Step {
+	var steps = make([]Step, 0, len(tr.Entries))
+
+	for _, e := range tr.Entries {
+		steps = append(steps, Step{
+			Read:   e.Prompt(),
+			NoEcho: e.Secret,
+			Write:  e.Input,
+			Secret: e.Secret,
+		})
+	}
+
+	return steps
+}
+
+// WriteGo writes Go source code for a QueueStory with the transcript steps.
+// Secret steps write undefined variables (secret1, secret2...), so the code
+// doesn't compile until they are defined with the secrets.
+func (tr *Transcript) WriteGo(w io.Writer) error {
+	var b bytes.Buffer
+	var secrets int
+
+	b.WriteString("var story = &pseudoterm.QueueStory{\nTimeout: 5 * time.Second,\n}\n\n")
+	b.WriteString("story.Add(\n")
+
+	for _, s := range tr.Steps() {
+		if s.Secret {
+			secrets++
+			fmt.Fprintf(&b, "pseudoterm.Step{\nRead: %s,\nNoEcho: true,\nWrite: secret%d, // TODO: define the secret input\nSecret: true,\n},\n",
+				strconv.Quote(s.Read),
+				secrets)
+			continue
+		}
+
+		fmt.Fprintf(&b, "pseudoterm.Step{\nRead: %s,\nWrite: %s,\n},\n",
+			strconv.Quote(s.Read),
+			strconv.Quote(s.Write))
+	}
+
+	b.WriteString(")\n")
+
+	var src, err = format.Source(b.Bytes())
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(src)
+	return err
+}
@@ -0,0 +1,272 @@
+//go:build !windows
+// +build !windows
+
+package pseudoterm_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+	"github.com/henvic/pseudoterm/pseudotermtest"
+)
+
+func TestRecorder(t *testing.T) {
+	var stdinReader, stdinWriter = io.Pipe()
+	var stdout = &pseudotermtest.Buffer{}
+	var echo = &pseudotermtest.Buffer{}
+
+	var r = &pseudoterm.Recorder{
+		Terminal: &pseudoterm.Terminal{
+			Command:    exec.Command("mocks/mock.sh"),
+			EchoStream: echo,
+		},
+		Stdin:  stdinReader,
+		Stdout: stdout,
+	}
+
+	go func() {
+		pseudoterm.WaitForOutput(t, stdout, "Your name:")
+		_, _ = io.WriteString(stdinWriter, "Henrique\n")
+		pseudoterm.WaitForOutput(t, stdout, "Your age:")
+		_, _ = io.WriteString(stdinWriter, "10\n")
+		pseudoterm.WaitForOutput(t, stdout, "Bye!")
+		_ = stdinWriter.Close()
+	}()
+
+	var tr, err = r.Record()
+
+	if err != nil {
+		t.Fatalf("Expected no error recording, got %v instead", err)
+	}
+
+	var wantSteps = []pseudoterm.Step{
+		pseudoterm.Step{
+			Read:  "Your name:",
+			Write: "Henrique",
+		},
+		pseudoterm.Step{
+			Read:  "Your age:",
+			Write: "10",
+		},
+	}
+
+	if steps := tr.Steps(); !reflect.DeepEqual(steps, wantSteps) {
+		t.Errorf("Expected steps to be %+v, got %+v instead", wantSteps, steps)
+	}
+
+	if strings.Contains(stdout.String(), "Your name: Henrique") {
+		t.Errorf("Expected echo of input to be dropped from output, got %q", stdout.String())
+	}
+
+	pseudotermtest.AssertSimilar(t, "Your age is 10\nBye!", tr.Tail)
+
+	if tr.ExitCode != 0 {
+		t.Errorf("Expected exit code 0, got %v instead", tr.ExitCode)
+	}
+
+	if r.Terminal.EchoStream != echo || r.Terminal.SkipBuffer {
+		t.Errorf("Expected EchoStream and SkipBuffer of the terminal to be restored")
+	}
+
+	if !strings.Contains(echo.String(), "Bye!") {
+		t.Errorf("Expected output on the echo stream, got %q instead", echo.String())
+	}
+}
+
+func TestRecorderInputWhileWriting(t *testing.T) {
+	var stdinReader, stdinWriter = io.Pipe()
+	var stdout = &pseudotermtest.Buffer{}
+
+	var r = &pseudoterm.Recorder{
+		Terminal: &pseudoterm.Terminal{
+			Transport: &pseudoterm.Program{
+				Func: func(stdin io.Reader, stdout io.Writer) int {
+					var done = make(chan struct{})
+
+					go func() {
+						_, _ = bufio.NewReader(stdin).ReadString('\n')
+						close(done)
+					}()
+
+					for {
+						select {
+						case <-done:
+							return 0
+						default:
+							fmt.Fprintln(stdout, "tick")
+							time.Sleep(time.Millisecond)
+						}
+					}
+				},
+			},
+		},
+		Stdin:  stdinReader,
+		Stdout: stdout,
+	}
+
+	go func() {
+		pseudoterm.WaitForOutput(t, stdout, "tick")
+		_, _ = io.WriteString(stdinWriter, "stop\n")
+		_ = stdinWriter.Close()
+	}()
+
+	var tr, err = r.Record()
+
+	if err != nil {
+		t.Fatalf("Expected no error recording, got %v instead", err)
+	}
+
+	if len(tr.Entries) != 1 || tr.Entries[0].Input != "stop" {
+		t.Errorf("Expected one entry with the input, got %+v instead", tr.Entries)
+	}
+}
+
+func TestRecorderWithBufferSize(t *testing.T) {
+	var stdout = &pseudotermtest.Buffer{}
+
+	var r = &pseudoterm.Recorder{
+		Terminal: &pseudoterm.Terminal{
+			Command:    exec.Command("seq", "1", "2000"),
+			BufferSize: 64,
+		},
+		Stdin:  strings.NewReader(""),
+		Stdout: stdout,
+	}
+
+	var done = make(chan error, 1)
+
+	go func() {
+		var _, err = r.Record()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error recording, got %v instead", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected recording to not block on the output buffer")
+	}
+
+	if !strings.Contains(stdout.String(), "2000") {
+		t.Errorf("Expected the whole output to be copied, got %q instead", stdout.String())
+	}
+}
+
+func TestRecorderSecret(t *testing.T) {
+	var stdinReader, stdinWriter = io.Pipe()
+	var stdout = &pseudotermtest.Buffer{}
+
+	var r = &pseudoterm.Recorder{
+		Terminal: &pseudoterm.Terminal{
+			Command: exec.Command("mocks/mock-password.sh"),
+		},
+		Stdin:  stdinReader,
+		Stdout: stdout,
+	}
+
+	go func() {
+		pseudoterm.WaitForOutput(t, stdout, "Your name:")
+		_, _ = io.WriteString(stdinWriter, "Henrique\n")
+		pseudoterm.WaitForOutput(t, stdout, "Secret code, please:")
+		// wait for the echo to be disabled
+		time.Sleep(100 * time.Millisecond)
+		_, _ = io.WriteString(stdinWriter, "hunter2\n")
+		pseudoterm.WaitForOutput(t, stdout, "characters")
+		_ = stdinWriter.Close()
+	}()
+
+	var tr, err = r.Record()
+
+	if err != nil {
+		t.Fatalf("Expected no error recording, got %v instead", err)
+	}
+
+	if len(tr.Entries) != 2 || tr.Entries[0].Secret || !tr.Entries[1].Secret || tr.Entries[1].Input != "" {
+		t.Errorf("Expected only the second entry to be secret, got %+v instead", tr.Entries)
+	}
+
+	var steps = tr.Steps()
+
+	if len(steps) != 2 || !steps[1].NoEcho || !steps[1].Secret {
+		t.Errorf("Expected step to wait for no echo and write a secret, got %+v instead", steps)
+	}
+
+	if !strings.Contains(stdout.String(), "your code has 7 characters") {
+		t.Errorf("Expected secret to reach the program, got %q instead", stdout.String())
+	}
+}
+
+func TestEntryPrompt(t *testing.T) {
+	var e = pseudoterm.Entry{
+		Output: "Your name is Henrique\r\n\r\nYour age: ",
+	}
+
+	if p := e.Prompt(); p != "Your age:" {
+		t.Errorf("Expected prompt to be %q, got %q instead", "Your age:", p)
+	}
+
+	if p := (pseudoterm.Entry{}).Prompt(); p != "" {
+		t.Errorf("Expected empty prompt, got %q instead", p)
+	}
+}
+
+func TestTranscriptWriteGo(t *testing.T) {
+	var tr = &pseudoterm.Transcript{
+		Entries: []pseudoterm.Entry{
+			pseudoterm.Entry{
+				Output: "Starting\r\nYour name: ",
+				Input:  "Henrique",
+			},
+			pseudoterm.Entry{
+				Output: "Your \"age\": ",
+				Input:  "10",
+			},
+			pseudoterm.Entry{
+				Output: "Password: ",
+				Secret: true,
+			},
+		},
+	}
+
+	var b bytes.Buffer
+
+	if err := tr.WriteGo(&b); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	var want = `var story = &pseudoterm.QueueStory{
+	Timeout: 5 * time.Second,
+}
+
+story.Add(
+	pseudoterm.Step{
+		Read:  "Your name:",
+		Write: "Henrique",
+	},
+	pseudoterm.Step{
+		Read:  "Your \"age\":",
+		Write: "10",
+	},
+	pseudoterm.Step{
+		Read:   "Password:",
+		NoEcho: true,
+		Write:  secret1, // TODO: define the secret input
+		Secret: true,
+	},
+)
+`
+
+	if b.String() != want {
+		t.Errorf("Expected Go source to be:\n%s\ngot:\n%s", want, b.String())
+	}
+}
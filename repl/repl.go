@@ -0,0 +1,275 @@
+/*
+Package repl drives interactive interpreters, like python3 or node, returning
+the output of each evaluation and detecting errors.
+
+	var r = repl.Python3()
+
+	if err := r.Start(ctx); err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	var out, err = r.Eval(ctx, "for i in range(3):\n    print(i)")
+
+An REPL is configured by its Prompt and Continuation patterns, and how the
+code is framed into lines. Each line is written after the previous one gets
+a prompt or a continuation prompt, and its echo is removed from the output.
+*/
+package repl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/henvic/pseudoterm"
+	"github.com/henvic/pseudoterm/internal/expect"
+)
+
+var (
+	// ErrIncomplete is returned when the code is incomplete, like an unclosed parenthesis
+	ErrIncomplete = errors.New("Incomplete code")
+
+	// ErrExited is returned when the interpreter exits
+	ErrExited = errors.New("Interpreter exited")
+
+	// ErrNotStarted is returned when evaluating code before starting the interpreter
+	ErrNotStarted = errors.New("Interpreter not started")
+)
+
+// EvalError is returned when the output of an evaluation matches the Error pattern,
+// with the output from the match on, as a traceback
+type EvalError struct {
+	Code      string
+	Output    string
+	Traceback string
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("Error evaluating %q:\n%s", e.Code, e.Traceback)
+}
+
+// Framing of the code into the lines written to the interpreter
+type Framing func(code string) []string
+
+// Lines frames each line of the code as is
+func Lines(code string) []string {
+	return strings.Split(strings.TrimRight(code, "\n"), "\n")
+}
+
+// Block frames the lines of the code followed by an empty line, what ends
+// indented blocks, as Python requires
+func Block(code string) []string {
+	var lines = Lines(code)
+
+	if len(lines) > 1 || strings.HasSuffix(lines[0], ":") {
+		lines = append(lines, "")
+	}
+
+	return lines
+}
+
+// REPL running Command on a pseudo tty.
+// Prompt and Continuation must match the end of the output while the
+// interpreter waits for input. Error detects errors in the output of an evaluation.
+// Framing defaults to Lines.
+type REPL struct {
+	Command      *exec.Cmd
+	Prompt       *regexp.Regexp
+	Continuation *regexp.Regexp
+	Error        *regexp.Regexp
+	Framing      Framing
+	EchoStream   io.Writer
+
+	term    *pseudoterm.Terminal
+	out     *expect.Buffer
+	prompts *regexp.Regexp
+	pending bool
+}
+
+// Python3 REPL
+func Python3() *REPL {
+	return &REPL{
+		Command:      command("python3", []string{"PYTHON_BASIC_REPL=1"}, "-q"),
+		Prompt:       regexp.MustCompile(`(^|\n)>>> $`),
+		Continuation: regexp.MustCompile(`(^|\n)\.\.\. $`),
+		Error:        regexp.MustCompile(`(?m)^(Traceback \(most recent call last\):|  File "<stdin>", line \d+)`),
+		Framing:      Block,
+	}
+}
+
+// Node REPL
+func Node() *REPL {
+	return &REPL{
+		Command:      command("node", []string{"NODE_NO_READLINE=1"}, "-i"),
+		Prompt:       regexp.MustCompile(`(^|\n)> $`),
+		Continuation: regexp.MustCompile(`(^|\n)\.\.\. $`),
+		Error:        regexp.MustCompile(`(?m)^Uncaught\b`),
+	}
+}
+
+// Sh REPL, a POSIX shell
+func Sh() *REPL {
+	return &REPL{
+		Command:      command("sh", []string{"PS1=$ ", "PS2=> "}, "-i"),
+		Prompt:       regexp.MustCompile(`(^|\n)\$ $`),
+		Continuation: regexp.MustCompile(`(^|\n)> $`),
+		Error:        regexp.MustCompile(`(?m)^sh: `),
+	}
+}
+
+func command(name string, env []string, args ...string) *exec.Cmd {
+	var cmd = exec.Command(name, args...)
+	cmd.Env = append(append(os.Environ(), "TERM=dumb"), env...)
+	return cmd
+}
+
+// Start the interpreter, waiting for its first prompt
+func (r *REPL) Start(ctx context.Context) error {
+	if r.term != nil {
+		return errors.New("Already started")
+	}
+
+	if r.Command == nil || r.Prompt == nil {
+		return errors.New("Missing command or prompt")
+	}
+
+	r.out = &expect.Buffer{}
+	r.prompts = r.Prompt
+
+	if r.Continuation != nil {
+		r.prompts = regexp.MustCompile(`(?:` + r.Prompt.String() + `)|(?:` + r.Continuation.String() + `)`)
+	}
+
+	var echo io.Writer = r.out
+
+	if r.EchoStream != nil {
+		echo = io.MultiWriter(r.out, r.EchoStream)
+	}
+
+	r.term = &pseudoterm.Terminal{
+		Command:    r.Command,
+		EchoStream: echo,
+		SkipBuffer: true,
+	}
+
+	if err := r.term.Start(); err != nil {
+		r.term = nil
+		return err
+	}
+
+	_, _, err := r.wait(ctx)
+	return err
+}
+
+// Eval writes the code to the interpreter, returning its output.
+// If the output matches the Error pattern, it returns an *EvalError.
+// If the context is done before the evaluation finishes, it is interrupted
+// and the next call to Eval waits for the interpreter to be ready again.
+func (r *REPL) Eval(ctx context.Context, code string) (output string, err error) {
+	if r.term == nil {
+		return "", ErrNotStarted
+	}
+
+	if r.pending {
+		if _, _, err = r.wait(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	var framing = r.Framing
+
+	if framing == nil {
+		framing = Lines
+	}
+
+	var continued bool
+
+	for _, line := range framing(code) {
+		if _, err = r.term.WriteLine(line); err != nil {
+			return output, err
+		}
+
+		var out string
+
+		if out, continued, err = r.wait(ctx); err != nil {
+			return output, err
+		}
+
+		output += strings.TrimPrefix(strings.TrimPrefix(out, line), "\n")
+	}
+
+	if continued {
+		r.interrupt()
+		return output, ErrIncomplete
+	}
+
+	if r.Error != nil {
+		if loc := r.Error.FindStringIndex(output); loc != nil {
+			return output, &EvalError{
+				Code:      code,
+				Output:    output,
+				Traceback: output[loc[0]:],
+			}
+		}
+	}
+
+	return output, nil
+}
+
+// Close the interpreter
+func (r *REPL) Close() error {
+	if r.term == nil {
+		return ErrNotStarted
+	}
+
+	var term = r.term
+	r.term = nil
+	r.pending = false
+
+	if term.Command.Process != nil {
+		_ = term.Command.Process.Kill()
+	}
+
+	_ = term.Stop()
+	term.Wait()
+	<-term.OutputDone()
+	return nil
+}
+
+// wait for the next prompt or continuation prompt
+func (r *REPL) wait(ctx context.Context) (output string, continued bool, err error) {
+	before, match, err := r.out.Expect(ctx, r.prompts, r.term.OutputDone())
+
+	switch {
+	case err == expect.ErrClosed:
+		return normalize(r.out.Take()), false, ErrExited
+	case err != nil:
+		r.interrupt()
+		return "", false, err
+	}
+
+	// keep the line break matched before the prompt
+	var prompt = strings.TrimLeft(match[0], "\r\n")
+	before += match[0][:len(match[0])-len(prompt)]
+
+	r.pending = false
+	return normalize(before), !r.Prompt.MatchString(match[0]), nil
+}
+
+// interrupt the evaluation, so the interpreter prints a prompt again
+func (r *REPL) interrupt() {
+	r.pending = true
+	_, _ = r.term.WriteString(pseudoterm.Keys["ctrl-c"])
+}
+
+// normalize the line endings of the pseudo tty
+func normalize(s string) string {
+	return strings.Replace(s, "\r\n", "\n", -1)
+}
@@ -0,0 +1,125 @@
+//go:build !windows
+// +build !windows
+
+package repl
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type evalCase struct {
+	code   string
+	output string
+	err    string
+}
+
+func testREPL(t *testing.T, r *REPL, cases []evalCase) {
+	if _, err := exec.LookPath(r.Command.Path); err != nil {
+		t.Skipf("Skipping: %v", err)
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Expected no error starting, got %v instead", err)
+	}
+
+	defer r.Close()
+
+	for _, c := range cases {
+		var output, err = r.Eval(ctx, c.code)
+
+		if output != c.output {
+			t.Errorf("Expected output of %q to be %q, got %q instead", c.code, c.output, output)
+		}
+
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("Expected no error evaluating %q, got %v instead", c.code, err)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("Expected error evaluating %q to contain %q, got %v instead", c.code, c.err, err)
+		}
+	}
+}
+
+func TestPython3(t *testing.T) {
+	testREPL(t, Python3(), []evalCase{
+		{"1+1", "2\n", ""},
+		{"for i in range(3):\n    print(i)", "0\n1\n2\n", ""},
+		{"x = 5\ndef f(y):\n    return x * y\n\nf(2)", "10\n", ""},
+		{"undefined_name", "Traceback (most recent call last):\n" +
+			"  File \"<stdin>\", line 1, in <module>\n" +
+			"NameError: name 'undefined_name' is not defined\n",
+			"NameError: name 'undefined_name' is not defined"},
+		{"(1,", "", "Incomplete code"},
+		{"print('still working')", "still working\n", ""},
+	})
+}
+
+func TestNode(t *testing.T) {
+	testREPL(t, Node(), []evalCase{
+		{"1+1", "2\n", ""},
+		{"function f() {\n  return 3\n}", "undefined\n", ""},
+		{"f()", "3\n", ""},
+		{"nope", "Uncaught ReferenceError: nope is not defined\n", "ReferenceError"},
+	})
+}
+
+func TestSh(t *testing.T) {
+	testREPL(t, Sh(), []evalCase{
+		{"echo hello", "hello\n", ""},
+		{"for i in 1 2; do\n  echo $i\ndone", "1\n2\n", ""},
+		{"nonexistent-command-xyz", "sh: 5: nonexistent-command-xyz: not found\n", "not found"},
+	})
+}
+
+func TestEvalTimeout(t *testing.T) {
+	var r = Sh()
+	var ctx = context.Background()
+
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Expected no error starting, got %v instead", err)
+	}
+
+	defer r.Close()
+
+	var tctx, cancel = context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.Eval(tctx, "sleep 10"); err != context.DeadlineExceeded {
+		t.Errorf("Expected error to be %v, got %v instead", context.DeadlineExceeded, err)
+	}
+
+	if output, err := r.Eval(ctx, "echo ok"); output != "ok\n" || err != nil {
+		t.Errorf("Expected interpreter to recover, got (%q, %v) instead", output, err)
+	}
+
+	if _, err := r.Eval(ctx, "exit"); err != ErrExited {
+		t.Errorf("Expected error to be %v, got %v instead", ErrExited, err)
+	}
+}
+
+func TestFraming(t *testing.T) {
+	var cases = []struct {
+		framing Framing
+		code    string
+		want    []string
+	}{
+		{Lines, "a\nb\n", []string{"a", "b"}},
+		{Block, "x = 1", []string{"x = 1"}},
+		{Block, "if x:", []string{"if x:", ""}},
+		{Block, "if x:\n    y()", []string{"if x:", "    y()", ""}},
+	}
+
+	for _, c := range cases {
+		if got := c.framing(c.code); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Expected framing of %q to be %q, got %q instead", c.code, c.want, got)
+		}
+	}
+}
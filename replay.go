@@ -0,0 +1,146 @@
+package pseudoterm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Replayer plays the program side of a recorded Transcript:
+// it prints the recorded output, waits for the recorded input and fails on divergence.
+// Use it to ship self-contained fake programs instead of shell mocks.
+type Replayer struct {
+	Transcript *Transcript
+	Stdin      io.Reader
+	Stdout     io.Writer
+}
+
+// ReplayError indicates the input received differs from the recorded one.
+// Secret inputs are redacted from it.
+type ReplayError struct {
+	Entry int
+	Want  string
+	Got   string
+	EOF   bool
+}
+
+func (e ReplayError) Error() string {
+	if e.EOF {
+		return fmt.Sprintf("Replay diverged on entry %d: wanted input %q, got EOF instead",
+			e.Entry,
+			e.Want)
+	}
+
+	return fmt.Sprintf("Replay diverged on entry %d: wanted input %q, got %q instead",
+		e.Entry,
+		e.Want,
+		e.Got)
+}
+
+// Replay the transcript.
+// Recorded line breaks are written as "\n", as a tty already converts them to "\r\n".
+func (r *Replayer) Replay() error {
+	var stdin, stdout = r.Stdin, r.Stdout
+
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	var br = bufio.NewReader(stdin)
+
+	for c, e := range r.Transcript.Entries {
+		if err := writeReplayOutput(stdout, e.Output); err != nil {
+			return err
+		}
+
+		line, err := br.ReadString('\n')
+
+		var want = e.Input
+
+		if e.Secret {
+			want = Redacted
+		}
+
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF {
+				return ReplayError{Entry: c, Want: want, EOF: true}
+			}
+
+			return err
+		}
+
+		var got = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case e.Secret && e.Input == "":
+			// secret inputs aren't recorded, so any input is accepted
+		case got != e.Input && e.Secret:
+			return ReplayError{Entry: c, Want: want, Got: Redacted}
+		case got != e.Input:
+			return ReplayError{Entry: c, Want: want, Got: got}
+		}
+	}
+
+	return writeReplayOutput(stdout, r.Transcript.Tail)
+}
+
+func writeReplayOutput(w io.Writer, s string) error {
+	_, err := io.WriteString(w, strings.Replace(s, "\r\n", "\n", -1))
+	return err
+}
+
+// TranscriptFromSteps creates a transcript a Replayer can use to play the program side of a story.
+// Each step prints its Read string and waits for its Write string, unless SkipWrite is set.
+// Secret steps are kept secret on the transcript.
+// Only steps using the Read matcher are supported.
+func TranscriptFromSteps(steps []Step) (*Transcript, error) {
+	var tr = &Transcript{}
+	var output string
+
+	for c, s := range steps {
+		if s.ReadRegex != nil || s.ReadFunc != nil {
+			return nil, fmt.Errorf("Step %d can't be replayed: only Read is supported", c)
+		}
+
+		if s.SkipWrite {
+			output += s.Read + "\n"
+			continue
+		}
+
+		tr.Entries = append(tr.Entries, Entry{
+			Output: output + s.Read + " ",
+			Input:  s.Write,
+			Secret: s.Secret,
+		})
+
+		output = ""
+	}
+
+	tr.Tail = output
+	return tr, nil
+}
+
+// ReadTranscript decodes a transcript encoded as JSON
+func ReadTranscript(r io.Reader) (*Transcript, error) {
+	var tr = &Transcript{}
+
+	if err := json.NewDecoder(r).Decode(tr); err != nil {
+		return nil, err
+	}
+
+	return tr, nil
+}
+
+// WriteJSON encodes the transcript as JSON
+func (tr *Transcript) WriteJSON(w io.Writer) error {
+	var e = json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	return e.Encode(tr)
+}
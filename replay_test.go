@@ -0,0 +1,160 @@
+package pseudoterm
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var replayTranscript = &Transcript{
+	Entries: []Entry{
+		Entry{
+			Output: "Starting\r\nYour name: ",
+			Input:  "Henrique",
+		},
+		Entry{
+			Output: "Your name is Henrique\r\nYour age: ",
+			Input:  "10",
+		},
+	},
+	Tail: "Your age is 10\r\nBye!\r\n",
+}
+
+func TestReplayer(t *testing.T) {
+	var stdout bytes.Buffer
+
+	var r = &Replayer{
+		Transcript: replayTranscript,
+		Stdin:      strings.NewReader("Henrique\n10\n"),
+		Stdout:     &stdout,
+	}
+
+	if err := r.Replay(); err != nil {
+		t.Errorf("Expected no error, got %v instead", err)
+	}
+
+	var want = "Starting\nYour name: Your name is Henrique\nYour age: Your age is 10\nBye!\n"
+
+	if stdout.String() != want {
+		t.Errorf("Expected output to be %q, got %q instead", want, stdout.String())
+	}
+}
+
+func TestReplayerDivergence(t *testing.T) {
+	var r = &Replayer{
+		Transcript: replayTranscript,
+		Stdin:      strings.NewReader("Henrique\n11\n"),
+		Stdout:     &bytes.Buffer{},
+	}
+
+	var err = r.Replay()
+	var wantErr = ReplayError{Entry: 1, Want: "10", Got: "11"}
+
+	if err != wantErr {
+		t.Errorf("Expected error to be %v, got %v instead", wantErr, err)
+	}
+
+	if msg := `Replay diverged on entry 1: wanted input "10", got "11" instead`; err.Error() != msg {
+		t.Errorf("Expected error message to be %v, got %v instead", msg, err)
+	}
+}
+
+func TestReplayerEOF(t *testing.T) {
+	var r = &Replayer{
+		Transcript: replayTranscript,
+		Stdin:      strings.NewReader("Henrique"),
+		Stdout:     &bytes.Buffer{},
+	}
+
+	var err = r.Replay()
+	var wantErr = ReplayError{Entry: 1, Want: "10", EOF: true}
+
+	if err != wantErr {
+		t.Errorf("Expected error to be %v, got %v instead", wantErr, err)
+	}
+
+	if msg := `Replay diverged on entry 1: wanted input "10", got EOF instead`; err.Error() != msg {
+		t.Errorf("Expected error message to be %v, got %v instead", msg, err)
+	}
+}
+
+func TestReplayerSecret(t *testing.T) {
+	var tr = &Transcript{
+		Entries: []Entry{
+			Entry{Output: "Password: ", Secret: true},
+			Entry{Output: "Token: ", Input: "hunter2", Secret: true},
+		},
+	}
+
+	var r = &Replayer{
+		Transcript: tr,
+		Stdin:      strings.NewReader("anything\nhunter3\n"),
+		Stdout:     &bytes.Buffer{},
+	}
+
+	var err = r.Replay()
+	var wantErr = ReplayError{Entry: 1, Want: Redacted, Got: Redacted}
+
+	if err != wantErr {
+		t.Errorf("Expected error to be %v, got %v instead", wantErr, err)
+	}
+}
+
+func TestTranscriptFromSteps(t *testing.T) {
+	var tr, err = TranscriptFromSteps([]Step{
+		Step{
+			Read:      "Starting",
+			SkipWrite: true,
+		},
+		Step{
+			Read:  "Your name:",
+			Write: "Henrique",
+		},
+		Step{
+			Read:      "Bye!",
+			SkipWrite: true,
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	var want = &Transcript{
+		Entries: []Entry{
+			Entry{
+				Output: "Starting\nYour name: ",
+				Input:  "Henrique",
+			},
+		},
+		Tail: "Bye!\n",
+	}
+
+	if !reflect.DeepEqual(tr, want) {
+		t.Errorf("Expected transcript to be %+v, got %+v instead", want, tr)
+	}
+
+	if _, err := TranscriptFromSteps([]Step{Step{ReadRegex: regexp.MustCompile("x")}}); err == nil {
+		t.Errorf("Expected error for step using ReadRegex")
+	}
+}
+
+func TestTranscriptJSON(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := replayTranscript.WriteJSON(&b); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	var tr, err = ReadTranscript(&b)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if !reflect.DeepEqual(tr, replayTranscript) {
+		t.Errorf("Expected transcript to be %+v, got %+v instead", replayTranscript, tr)
+	}
+}
@@ -0,0 +1,434 @@
+/*
+Package script runs Starlark scripts as pseudoterm stories,
+so scenarios with conditionals and loops can live in text files.
+
+Scripts can use the following builtins besides the Starlark ones:
+
+	expect(*patterns, timeout=None, fail=True)
+		waits for the output to match any of the regular expressions and
+		returns the index of the pattern matched. Output up to the end of the
+		match is consumed. On timeout, fails or returns -1 if fail is False.
+	capture(pattern, timeout=None)
+		like expect, but returns the first group of the match
+		(or the whole match, if the pattern has no groups).
+	send(s)
+		writes s to the terminal as is.
+	sendline(s)
+		writes s to the terminal followed by a line break.
+	keys(*names)
+		presses the given keys (see pseudoterm.Keys for names).
+	sleep(seconds)
+		waits for the given number of seconds.
+
+Values passed on Story.Vars are available on the vars dict. Example:
+
+	logged = False
+
+	for attempt in range(3):
+		expect("Password:")
+		sendline(vars["password"])
+		if expect("Welcome", "Try again", timeout=5) == 0:
+			logged = True
+			break
+
+	if not logged:
+		fail("couldn't login")
+*/
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+	"github.com/henvic/pseudoterm/internal/expect"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Story running a Starlark script on a Terminal.
+// Source is read from Filename, if nil.
+// ExpectTimeout is the timeout of expect and capture calls without one.
+type Story struct {
+	Terminal      *pseudoterm.Terminal
+	Filename      string
+	Source        interface{}
+	Vars          map[string]string
+	Timeout       time.Duration
+	ExpectTimeout time.Duration
+	Print         io.Writer
+
+	mu            sync.Mutex
+	out           expect.Buffer
+	ctx           context.Context
+	ctxCancelFunc context.CancelFunc
+	ended         chan struct{}
+	done          chan struct{}
+	err           error
+}
+
+var (
+	errAlreadyInitialized = errors.New("Story has already initialized")
+	errTimeout            = errors.New("timed out")
+	errOutputEnded        = errors.New("program output ended")
+
+	// scripts are allowed to have control flow on the top-level
+	fileOptions = &syntax.FileOptions{
+		While:           true,
+		TopLevelControl: true,
+		GlobalReassign:  true,
+		Set:             true,
+	}
+)
+
+// Setup starts running the script
+func (s *Story) Setup() (ctx context.Context, err error) {
+	if s.ctx != nil {
+		return nil, errAlreadyInitialized
+	}
+
+	s.ended = make(chan struct{})
+	s.done = make(chan struct{})
+	s.ctx, s.ctxCancelFunc = context.WithCancel(context.Background())
+
+	if s.Timeout != time.Duration(0) {
+		s.ctx, s.ctxCancelFunc = context.WithTimeout(s.ctx, s.Timeout)
+	}
+
+	var thread = &starlark.Thread{
+		Name:  s.Filename,
+		Print: s.print,
+	}
+
+	go func() {
+		<-s.ctx.Done()
+		thread.Cancel(s.ctx.Err().Error())
+	}()
+
+	go func() {
+		var err = s.exec(thread)
+
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		close(s.done)
+	}()
+
+	return s.ctx, nil
+}
+
+func (s *Story) exec(thread *starlark.Thread) error {
+	var vars = starlark.NewDict(len(s.Vars))
+
+	for k, v := range s.Vars {
+		if err := vars.SetKey(starlark.String(k), starlark.String(v)); err != nil {
+			return err
+		}
+	}
+
+	var predeclared = starlark.StringDict{
+		"vars":     vars,
+		"expect":   starlark.NewBuiltin("expect", s.expect),
+		"capture":  starlark.NewBuiltin("capture", s.capture),
+		"send":     starlark.NewBuiltin("send", s.send),
+		"sendline": starlark.NewBuiltin("sendline", s.sendline),
+		"keys":     starlark.NewBuiltin("keys", s.keys),
+		"sleep":    starlark.NewBuiltin("sleep", s.sleep),
+	}
+
+	var _, err = starlark.ExecFileOptions(fileOptions, thread, s.Filename, s.Source, predeclared)
+
+	if ee, ok := err.(*starlark.EvalError); ok {
+		return errors.New(ee.Backtrace())
+	}
+
+	return err
+}
+
+// Teardown cancels the script and waits for it to end.
+// As no more output is handled, expect and capture fail if they don't match what is left.
+func (s *Story) Teardown() {
+	if s.ctxCancelFunc == nil {
+		return
+	}
+
+	close(s.ended)
+	s.ctxCancelFunc()
+	<-s.done
+}
+
+// Cancel the script
+func (s *Story) Cancel() {
+	s.ctxCancelFunc()
+}
+
+// TickHandler returns the script error, if it failed
+func (s *Story) TickHandler() error {
+	select {
+	case <-s.done:
+		return s.Err()
+	default:
+		return nil
+	}
+}
+
+// HandleLine passes the output to the script. The script writes to the terminal by itself.
+func (s *Story) HandleLine(line string) (in string, err error) {
+	select {
+	case <-s.done:
+		return "", pseudoterm.SkipZeroMatches
+	default:
+	}
+
+	_, _ = s.out.Write([]byte(line))
+	return "", pseudoterm.SkipWrite
+}
+
+// Err returns the error the script failed with, if any
+func (s *Story) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Success tells if the script has run to the end without errors
+func (s *Story) Success() bool {
+	if s.done == nil {
+		return false
+	}
+
+	select {
+	case <-s.done:
+		return s.Err() == nil
+	default:
+		return false
+	}
+}
+
+func (s *Story) print(thread *starlark.Thread, msg string) {
+	var w = s.Print
+
+	if w == nil {
+		w = os.Stderr
+	}
+
+	fmt.Fprintln(w, msg)
+}
+
+// wait for the output to match any of the regular expressions
+func (s *Story) wait(timeout time.Duration, res []*regexp.Regexp) (index int, match []string, err error) {
+	if timeout == 0 {
+		timeout = s.ExpectTimeout
+	}
+
+	var ctx = s.ctx
+
+	if timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(s.ctx, timeout)
+		defer cancel()
+	}
+
+	index, match, err = s.out.ExpectAny(ctx, res, s.ended)
+
+	switch {
+	case err == expect.ErrClosed:
+		err = errOutputEnded
+	case err != nil && s.ctx.Err() != nil:
+		err = s.ctx.Err()
+	case err != nil:
+		err = errTimeout
+	}
+
+	return index, match, err
+}
+
+func compile(fn string, patterns []starlark.Value) ([]*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("%s: missing pattern", fn)
+	}
+
+	var res []*regexp.Regexp
+
+	for _, p := range patterns {
+		var ps, ok = starlark.AsString(p)
+
+		if !ok {
+			return nil, fmt.Errorf("%s: pattern must be a string, got %s", fn, p.Type())
+		}
+
+		re, err := regexp.Compile(ps)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fn, err)
+		}
+
+		res = append(res, re)
+	}
+
+	return res, nil
+}
+
+func seconds(v starlark.Value) (time.Duration, error) {
+	if v == starlark.None || v == nil {
+		return 0, nil
+	}
+
+	var f, ok = starlark.AsFloat(v)
+
+	if !ok || f < 0 {
+		return 0, fmt.Errorf("want a non-negative number of seconds, got %s", v)
+	}
+
+	return time.Duration(f * float64(time.Second)), nil
+}
+
+func (s *Story) expect(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var timeout starlark.Value = starlark.None
+	var fail = true
+
+	if err := starlark.UnpackArgs(b.Name(), nil, kwargs, "timeout?", &timeout, "fail?", &fail); err != nil {
+		return nil, err
+	}
+
+	var res, err = compile(b.Name(), args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := seconds(timeout)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+
+	index, _, err := s.wait(d, res)
+
+	switch {
+	case err == errTimeout && !fail:
+		return starlark.MakeInt(-1), nil
+	case err != nil:
+		return nil, fmt.Errorf("%s: %v waiting for %v", b.Name(), err, args)
+	}
+
+	return starlark.MakeInt(index), nil
+}
+
+func (s *Story) capture(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern string
+	var timeout starlark.Value = starlark.None
+
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "pattern", &pattern, "timeout?", &timeout); err != nil {
+		return nil, err
+	}
+
+	var res, err = compile(b.Name(), []starlark.Value{starlark.String(pattern)})
+
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := seconds(timeout)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+
+	_, match, err := s.wait(d, res)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v waiting for %q", b.Name(), err, pattern)
+	}
+
+	if len(match) > 1 {
+		return starlark.String(match[1]), nil
+	}
+
+	return starlark.String(match[0]), nil
+}
+
+func (s *Story) send(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var in string
+
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &in); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Terminal.WriteString(in); err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+
+	return starlark.None, nil
+}
+
+func (s *Story) sendline(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var in string
+
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &in); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Terminal.WriteLine(in); err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+
+	return starlark.None, nil
+}
+
+func (s *Story) keys(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(kwargs) != 0 {
+		return nil, fmt.Errorf("%s: unexpected keyword arguments", b.Name())
+	}
+
+	var names []string
+
+	for _, a := range args {
+		var n, ok = starlark.AsString(a)
+
+		if !ok {
+			return nil, fmt.Errorf("%s: key name must be a string, got %s", b.Name(), a.Type())
+		}
+
+		names = append(names, n)
+	}
+
+	var in, err = pseudoterm.KeySequence(names...)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+
+	if _, err := s.Terminal.WriteString(in); err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+
+	return starlark.None, nil
+}
+
+func (s *Story) sleep(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var v starlark.Value
+
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &v); err != nil {
+		return nil, err
+	}
+
+	var d, err = seconds(v)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+
+	select {
+	case <-time.After(d):
+		return starlark.None, nil
+	case <-s.ctx.Done():
+		return nil, fmt.Errorf("%s: %v", b.Name(), s.ctx.Err())
+	}
+}
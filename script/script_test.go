@@ -0,0 +1,177 @@
+//go:build !windows
+// +build !windows
+
+package script
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+)
+
+func TestStoryWithRetries(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var printStream = &bytes.Buffer{}
+	var term = &pseudoterm.Terminal{
+		Command:    exec.Command("../mocks/mock-login.sh"),
+		EchoStream: echoStream,
+	}
+
+	var story = &Story{
+		Terminal: term,
+		Filename: "login.star",
+		Source: `
+attempts = 0
+logged = False
+
+for password in vars["passwords"].split(","):
+    expect("Password:")
+    sendline(password)
+    attempts += 1
+    if expect("Welcome", "Try again") == 0:
+        logged = True
+        break
+
+if not logged:
+    fail("couldn't login")
+
+expect("Token:")
+send("abc")
+keys("enter")
+print("attempts:", attempts, "token:", capture("Token is ([a-z]+)"))
+`,
+		Vars: map[string]string{
+			"passwords": "wrong,secret,unused",
+		},
+		Timeout:       5 * time.Second,
+		ExpectTimeout: time.Second,
+		Print:         printStream,
+	}
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success: %v", story.Err())
+	}
+
+	if want := "attempts: 2 token: abc\n"; printStream.String() != want {
+		t.Errorf("Expected print output to be %q, got %q instead", want, printStream.String())
+	}
+
+	if !strings.Contains(echoStream.String(), "Token is abc") {
+		t.Errorf("Unexpected output %q", echoStream.String())
+	}
+}
+
+func TestStoryExpectTimeout(t *testing.T) {
+	var term = &pseudoterm.Terminal{
+		Command: exec.Command("../mocks/mock-login.sh"),
+	}
+
+	var story = &Story{
+		Terminal: term,
+		Filename: "timeout.star",
+		Source: `
+if expect("Username:", timeout=0.05, fail=False) != -1:
+    fail("unexpected username prompt")
+
+expect("Username:", timeout=0.05)
+`,
+		Timeout: 5 * time.Second,
+	}
+
+	var err = term.Run(story)
+
+	if err == nil || !strings.Contains(err.Error(), `expect: timed out waiting for ("Username:",)`) {
+		t.Errorf("Expected timeout error, got %v instead", err)
+	}
+
+	if story.Success() {
+		t.Errorf("Story should have not succeeded.")
+	}
+}
+
+func TestStoryErrors(t *testing.T) {
+	var cases = []struct {
+		source string
+		err    string
+	}{
+		{`expect()`, "expect: missing pattern"},
+		{`expect(1)`, "expect: pattern must be a string, got int"},
+		{`expect("(")`, "expect: error parsing regexp"},
+		{`expect("x", timeout=-1)`, "expect: want a non-negative number of seconds, got -1"},
+		{`keys("hyper")`, `keys: Unknown key "hyper"`},
+		{`sleep("x")`, `sleep: want a non-negative number of seconds, got "x"`},
+		{`capture()`, "capture: missing argument for pattern"},
+		{`syntax error`, "error.star:1:13: got identifier, want newline"},
+	}
+
+	for _, c := range cases {
+		var term = &pseudoterm.Terminal{
+			Command: exec.Command("../mocks/mock-login.sh"),
+		}
+
+		var story = &Story{
+			Terminal: term,
+			Filename: "error.star",
+			Source:   c.source,
+			Timeout:  5 * time.Second,
+		}
+
+		if err := term.Run(story); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("Expected error running %q to contain %q, got %v instead", c.source, c.err, err)
+		}
+	}
+}
+
+func TestStoryAlreadyInitialized(t *testing.T) {
+	var story = &Story{
+		Source: `sleep(0)`,
+	}
+
+	if _, err := story.Setup(); err != nil {
+		t.Errorf("Expected no error, got %v instead", err)
+	}
+
+	if _, err := story.Setup(); err != errAlreadyInitialized {
+		t.Errorf("Expected error %v, got %v instead", errAlreadyInitialized, err)
+	}
+
+	story.Teardown()
+}
+
+func TestStoryTeardownCancelsSleep(t *testing.T) {
+	var story = &Story{
+		Source: `
+while True:
+    sleep(10)
+`,
+	}
+
+	if _, err := story.Setup(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	var done = make(chan struct{})
+
+	go func() {
+		story.Teardown()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected teardown to cancel the sleeping script")
+	}
+
+	if story.Success() {
+		t.Errorf("Story should have not succeeded.")
+	}
+}
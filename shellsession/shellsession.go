@@ -0,0 +1,226 @@
+/*
+Package shellsession runs many commands on a persistent shell, telling where
+the output of each command ends and its exit status.
+
+	var s = &shellsession.Session{}
+
+	if err := s.Start(ctx); err != nil {
+		return err
+	}
+
+	defer s.Close()
+
+	var out, exitCode, err = s.Exec(ctx, "cd /tmp && ls")
+
+The shell prompt is replaced by a unique marker with the exit status of the
+last command, and the terminal echo is disabled, so the output of a command
+is whatever comes before the next prompt. Commands run in a group, so they
+might span multiple lines, and the shell state (such as the working directory
+and variables) is kept between them.
+*/
+package shellsession
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/henvic/pseudoterm"
+	"github.com/henvic/pseudoterm/internal/expect"
+)
+
+var (
+	// ErrExited is returned when the shell exits, what can happen due to the command
+	ErrExited = errors.New("Shell exited")
+
+	// ErrNotStarted is returned when executing commands before starting the session
+	ErrNotStarted = errors.New("Shell not started")
+)
+
+// Session of a shell: bash (the default), zsh or another POSIX sh, like dash.
+// Shell might be a path. Env is appended to the environment of the current process.
+// The shell starts without reading its startup files.
+type Session struct {
+	Shell      string
+	Env        []string
+	Dir        string
+	EchoStream io.Writer
+
+	term    *pseudoterm.Terminal
+	out     *expect.Buffer
+	marker  string
+	prompt  *regexp.Regexp
+	pending bool
+}
+
+// Start the shell, waiting for its first prompt
+func (s *Session) Start(ctx context.Context) error {
+	if s.term != nil {
+		return errors.New("Already started")
+	}
+
+	var id = make([]byte, 8)
+
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	s.marker = "__pseudoterm_" + hex.EncodeToString(id) + "__"
+	s.prompt = regexp.MustCompile(`\r?\n` + s.marker + `(\d+)` + s.marker)
+	s.out = &expect.Buffer{}
+
+	var shell = s.shell()
+	var cmd = exec.Command(shell, args(shell)...)
+	cmd.Env = append(append(os.Environ(), "TERM=dumb"), s.Env...)
+	cmd.Dir = s.Dir
+
+	var echo io.Writer = s.out
+
+	if s.EchoStream != nil {
+		echo = io.MultiWriter(s.out, s.EchoStream)
+	}
+
+	s.term = &pseudoterm.Terminal{
+		Command:    cmd,
+		EchoStream: echo,
+		SkipBuffer: true,
+	}
+
+	if err := s.term.Start(); err != nil {
+		s.term = nil
+		return err
+	}
+
+	// the setup line is echoed, so the marker is split to not be found in it
+	if _, err := s.term.WriteLine(setup(shell, s.marker)); err != nil {
+		return err
+	}
+
+	_, _, err := s.wait(ctx)
+	return err
+}
+
+// Exec runs the command, returning its output and exit status.
+// If the context is done before the command finishes, it is interrupted
+// and the next call to Exec waits for the shell to be ready again.
+func (s *Session) Exec(ctx context.Context, command string) (output string, exitCode int, err error) {
+	if s.term == nil {
+		return "", -1, ErrNotStarted
+	}
+
+	select {
+	case <-s.term.OutputDone():
+		return "", -1, ErrExited
+	default:
+	}
+
+	if s.pending {
+		if _, _, err = s.wait(ctx); err != nil {
+			return "", -1, err
+		}
+	}
+
+	// commands run in a group to get a single prompt even when they span multiple lines
+	if _, err = s.term.WriteString("{ " + command + "\n}\n"); err != nil {
+		return "", -1, err
+	}
+
+	return s.wait(ctx)
+}
+
+// Restart the shell, stopping the current one.
+// The state of the shell (such as variables) is lost.
+func (s *Session) Restart(ctx context.Context) error {
+	if err := s.Close(); err != nil && err != ErrNotStarted {
+		return err
+	}
+
+	return s.Start(ctx)
+}
+
+// Close the session, stopping the shell
+func (s *Session) Close() error {
+	if s.term == nil {
+		return ErrNotStarted
+	}
+
+	var term = s.term
+	s.term = nil
+	s.pending = false
+
+	if term.Command.Process != nil {
+		_ = term.Command.Process.Kill()
+	}
+
+	_ = term.Stop()
+	term.Wait()
+	<-term.OutputDone()
+	return nil
+}
+
+// wait for the next prompt
+func (s *Session) wait(ctx context.Context) (output string, exitCode int, err error) {
+	before, match, err := s.out.Expect(ctx, s.prompt, s.term.OutputDone())
+
+	switch {
+	case err == expect.ErrClosed:
+		s.term.Wait()
+		return normalize(s.out.Take()), s.term.ExitCode(), ErrExited
+	case err != nil:
+		s.pending = true
+		_, _ = s.term.WriteString(pseudoterm.Keys["ctrl-c"])
+		return "", -1, err
+	}
+
+	s.pending = false
+	exitCode, _ = strconv.Atoi(match[1])
+	return normalize(before), exitCode, nil
+}
+
+func (s *Session) shell() string {
+	if s.Shell == "" {
+		return "bash"
+	}
+
+	return s.Shell
+}
+
+// args to start the shell interactively, without startup files or line editing
+func args(shell string) []string {
+	switch filepath.Base(shell) {
+	case "bash":
+		return []string{"--norc", "--noprofile", "--noediting", "-i"}
+	case "zsh":
+		return []string{"-f", "-i"}
+	default:
+		return []string{"-i"}
+	}
+}
+
+func setup(shell, marker string) string {
+	var split = fmt.Sprintf(`"%s""%s"`, marker[:len(marker)/2], marker[len(marker)/2:])
+	var status = `'$?'`
+	var options = "unset PROMPT_COMMAND; "
+
+	if filepath.Base(shell) == "zsh" {
+		status = `'%?'`
+		options = "unsetopt zle prompt_sp prompt_cr; RPROMPT=''; "
+	}
+
+	return options + `stty -echo; nl="$(printf '\nx')"; ` +
+		`PS1="${nl%x}"` + split + status + split + `; PS2=''; unset nl`
+}
+
+// normalize the line endings of the pseudo tty
+func normalize(s string) string {
+	return strings.Replace(s, "\r\n", "\n", -1)
+}
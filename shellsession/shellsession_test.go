@@ -0,0 +1,119 @@
+//go:build !windows
+// +build !windows
+
+package shellsession
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExec(t *testing.T) {
+	for _, shell := range []string{"bash", "sh"} {
+		var s = &Session{
+			Shell: shell,
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := s.Start(ctx); err != nil {
+			t.Fatalf("Expected no error starting %v, got %v instead", shell, err)
+		}
+
+		var cases = []struct {
+			command  string
+			output   string
+			exitCode int
+		}{
+			{"echo hello", "hello\n", 0},
+			{"printf abc", "abc", 0},
+			{"false", "", 1},
+			{"FOO=bar; cd /", "", 0},
+			{"echo $FOO; pwd", "bar\n/\n", 0},
+			{"for i in 1 2 3; do\n  echo $i\ndone\nexit_code=7", "1\n2\n3\n", 0},
+			{"echo multi\necho line; sh -c 'exit 3'", "multi\nline\n", 3},
+			{"printf '\\n\\n'", "\n\n", 0},
+		}
+
+		for _, c := range cases {
+			var output, exitCode, err = s.Exec(ctx, c.command)
+
+			if err != nil {
+				t.Errorf("Expected no error running %q on %v, got %v instead", c.command, shell, err)
+			}
+
+			if output != c.output || exitCode != c.exitCode {
+				t.Errorf("Expected %q on %v to return (%q, %d), got (%q, %d) instead",
+					c.command, shell, c.output, c.exitCode, output, exitCode)
+			}
+		}
+
+		if err := s.Close(); err != nil {
+			t.Errorf("Expected no error closing %v, got %v instead", shell, err)
+		}
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	var s = &Session{}
+	var ctx = context.Background()
+
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Expected no error starting, got %v instead", err)
+	}
+
+	defer s.Close()
+
+	var tctx, cancel = context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+
+	if _, _, err := s.Exec(tctx, "sleep 10"); err != context.DeadlineExceeded {
+		t.Errorf("Expected error to be %v, got %v instead", context.DeadlineExceeded, err)
+	}
+
+	var output, exitCode, err = s.Exec(ctx, "echo ok")
+
+	if output != "ok\n" || exitCode != 0 || err != nil {
+		t.Errorf("Expected shell to recover after interruption, got (%q, %d, %v) instead", output, exitCode, err)
+	}
+}
+
+func TestExecExitAndRestart(t *testing.T) {
+	var s = &Session{
+		Env: []string{"GREETING=hi"},
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, _, err := s.Exec(ctx, "true"); err != ErrNotStarted {
+		t.Errorf("Expected error to be %v, got %v instead", ErrNotStarted, err)
+	}
+
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Expected no error starting, got %v instead", err)
+	}
+
+	var output, exitCode, err = s.Exec(ctx, "echo $GREETING; exit 4")
+
+	if err != ErrExited || exitCode != 4 || !strings.Contains(output, "hi\n") {
+		t.Errorf("Expected shell to exit with (hi, 4, %v), got (%q, %d, %v) instead", ErrExited, output, exitCode, err)
+	}
+
+	if _, _, err := s.Exec(ctx, "true"); err != ErrExited {
+		t.Errorf("Expected error to be %v, got %v instead", ErrExited, err)
+	}
+
+	if err := s.Restart(ctx); err != nil {
+		t.Fatalf("Expected no error restarting, got %v instead", err)
+	}
+
+	defer s.Close()
+
+	if output, _, err = s.Exec(ctx, "echo $GREETING"); output != "hi\n" || err != nil {
+		t.Errorf("Expected (hi, nil) after restart, got (%q, %v) instead", output, err)
+	}
+}
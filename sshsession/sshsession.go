@@ -0,0 +1,200 @@
+/*
+Package sshsession is a pseudoterm Transport running programs on remote hosts
+over an SSH session with a pseudo tty.
+
+	var term = &pseudoterm.Terminal{
+		Transport: &sshsession.Session{
+			Client:  client, // from ssh.Dial
+			Command: "./install.sh",
+		},
+	}
+
+Resizing the terminal sends a window change request, and signals are sent as
+SSH signal requests. The exit status of the remote command is available with
+Terminal.ExitCode(). Sessions ending without an exit status are reported
+as an ExitError of the pseudoterm.ExecutionError returned by Terminal.Run.
+*/
+package sshsession
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// DefaultTerm is the terminal type requested when Term is not set
+	DefaultTerm = "xterm"
+
+	// DefaultRows is the number of rows requested when Rows is not set
+	DefaultRows uint16 = 24
+
+	// DefaultCols is the number of columns requested when Cols is not set
+	DefaultCols uint16 = 80
+)
+
+// Session runs Command on a new session of the SSH Client, or a shell if Command is empty.
+// Term, Rows, Cols and Modes are used when requesting the pseudo tty.
+type Session struct {
+	Client  *ssh.Client
+	Command string
+	Term    string
+	Rows    uint16
+	Cols    uint16
+	Modes   ssh.TerminalModes
+
+	session *ssh.Session
+	stdin   io.WriteCloser
+	stdout  *io.PipeReader
+	out     *io.PipeWriter
+}
+
+// Start the session
+func (s *Session) Start() (err error) {
+	if s.session != nil {
+		return errors.New("Already started")
+	}
+
+	if s.Client == nil {
+		return errors.New("Missing SSH client")
+	}
+
+	if s.session, err = s.Client.NewSession(); err != nil {
+		s.session = nil
+		return err
+	}
+
+	if err = s.start(); err != nil {
+		_ = s.session.Close()
+		return err
+	}
+
+	return nil
+}
+
+func (s *Session) start() (err error) {
+	var rows, cols = s.Rows, s.Cols
+
+	if rows == 0 {
+		rows = DefaultRows
+	}
+
+	if cols == 0 {
+		cols = DefaultCols
+	}
+
+	var term = s.Term
+
+	if term == "" {
+		term = DefaultTerm
+	}
+
+	var modes = s.Modes
+
+	if modes == nil {
+		modes = ssh.TerminalModes{}
+	}
+
+	if err = s.session.RequestPty(term, int(rows), int(cols), modes); err != nil {
+		return err
+	}
+
+	if s.stdin, err = s.session.StdinPipe(); err != nil {
+		return err
+	}
+
+	// the remote pseudo tty combines stdout and stderr
+	s.stdout, s.out = io.Pipe()
+	s.session.Stdout = s.out
+	s.session.Stderr = s.out
+
+	if s.Command == "" {
+		return s.session.Shell()
+	}
+
+	return s.session.Start(s.Command)
+}
+
+// Read the output of the program
+func (s *Session) Read(b []byte) (n int, err error) {
+	return s.stdout.Read(b)
+}
+
+// Write input to the program
+func (s *Session) Write(b []byte) (n int, err error) {
+	return s.stdin.Write(b)
+}
+
+// Close the session
+func (s *Session) Close() error {
+	var err = s.session.Close()
+
+	if err == io.EOF {
+		err = nil
+	}
+
+	_ = s.stdout.Close()
+	return err
+}
+
+// Wait for the program to end. Programs killed by a signal exit with 128 plus the
+// signal number, like on shells. An error is returned if there is no exit status.
+func (s *Session) Wait() (exitCode int, err error) {
+	err = s.session.Wait()
+	_ = s.out.Close()
+
+	switch e := err.(type) {
+	case nil:
+		return 0, nil
+	case *ssh.ExitError:
+		return e.ExitStatus(), nil
+	default:
+		return -1, err
+	}
+}
+
+// SetSize sends a window change request, or sets the size to request on Start
+func (s *Session) SetSize(rows, cols uint16) error {
+	s.Rows, s.Cols = rows, cols
+
+	if s.session == nil {
+		return nil
+	}
+
+	return s.session.WindowChange(int(rows), int(cols))
+}
+
+var signals = map[syscall.Signal]ssh.Signal{
+	syscall.SIGABRT: ssh.SIGABRT,
+	syscall.SIGALRM: ssh.SIGALRM,
+	syscall.SIGFPE:  ssh.SIGFPE,
+	syscall.SIGHUP:  ssh.SIGHUP,
+	syscall.SIGILL:  ssh.SIGILL,
+	syscall.SIGINT:  ssh.SIGINT,
+	syscall.SIGKILL: ssh.SIGKILL,
+	syscall.SIGPIPE: ssh.SIGPIPE,
+	syscall.SIGQUIT: ssh.SIGQUIT,
+	syscall.SIGSEGV: ssh.SIGSEGV,
+	syscall.SIGTERM: ssh.SIGTERM,
+}
+
+// Signal sends a signal request to the program
+func (s *Session) Signal(sig os.Signal) error {
+	var ss, ok = sig.(syscall.Signal)
+
+	if !ok {
+		return fmt.Errorf("Unsupported signal %v", sig)
+	}
+
+	name, ok := signals[ss]
+
+	if !ok {
+		return fmt.Errorf("Unsupported signal %v", sig)
+	}
+
+	return s.session.Signal(name)
+}
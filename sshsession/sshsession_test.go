@@ -0,0 +1,362 @@
+package sshsession
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+	"golang.org/x/crypto/ssh"
+)
+
+// testSession is the server side of a session, playing the program
+type testSession struct {
+	ch      ssh.Channel
+	term    string
+	rows    uint32
+	cols    uint32
+	sizes   chan string
+	signals chan string
+}
+
+func (s *testSession) run(command string) {
+	switch command {
+	case "", "greet":
+		fmt.Fprint(s.ch, "Your name: ")
+		var name, _ = bufio.NewReader(s.ch).ReadString('\n')
+		fmt.Fprintf(s.ch, "Hi, %s\r\n", name[:len(name)-1])
+		s.exit(3)
+	case "size":
+		fmt.Fprintf(s.ch, "%s %dx%d\r\n", s.term, s.rows, s.cols)
+		fmt.Fprintf(s.ch, "%s\r\n", <-s.sizes)
+		s.exit(0)
+	case "signal":
+		fmt.Fprint(s.ch, "Waiting\r\n")
+		var sig = <-s.signals
+		_, _ = s.ch.SendRequest("exit-signal", false, ssh.Marshal(struct {
+			Signal     string
+			CoreDumped bool
+			Error      string
+			Lang       string
+		}{Signal: sig}))
+		_ = s.ch.Close()
+	case "vanish":
+		fmt.Fprint(s.ch, "Bye\r\n")
+		_ = s.ch.Close()
+	default:
+		fmt.Fprintf(s.ch, "unknown command %q\r\n", command)
+		s.exit(127)
+	}
+}
+
+func (s *testSession) exit(status uint32) {
+	_, _ = s.ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+	_ = s.ch.Close()
+}
+
+func (s *testSession) handle(reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		var ok = true
+
+		switch req.Type {
+		case "pty-req":
+			var p struct {
+				Term                      string
+				Cols, Rows, Width, Height uint32
+				Modes                     string
+			}
+
+			ok = ssh.Unmarshal(req.Payload, &p) == nil
+			s.term, s.rows, s.cols = p.Term, p.Rows, p.Cols
+		case "window-change":
+			var p struct {
+				Cols, Rows, Width, Height uint32
+			}
+
+			ok = ssh.Unmarshal(req.Payload, &p) == nil
+			s.sizes <- fmt.Sprintf("%dx%d", p.Rows, p.Cols)
+		case "signal":
+			var p struct {
+				Signal string
+			}
+
+			ok = ssh.Unmarshal(req.Payload, &p) == nil
+			s.signals <- p.Signal
+		case "shell":
+			go s.run("")
+		case "exec":
+			var p struct {
+				Command string
+			}
+
+			ok = ssh.Unmarshal(req.Payload, &p) == nil
+			go s.run(p.Command)
+		default:
+			ok = false
+		}
+
+		if req.WantReply {
+			_ = req.Reply(ok, nil)
+		}
+	}
+}
+
+func serve(l net.Listener, config *ssh.ServerConfig) {
+	for {
+		var nc, err = l.Accept()
+
+		if err != nil {
+			return
+		}
+
+		go func() {
+			_, chans, reqs, err := ssh.NewServerConn(nc, config)
+
+			if err != nil {
+				return
+			}
+
+			go ssh.DiscardRequests(reqs)
+
+			for nch := range chans {
+				if nch.ChannelType() != "session" {
+					_ = nch.Reject(ssh.UnknownChannelType, "unknown channel type")
+					continue
+				}
+
+				ch, reqs, err := nch.Accept()
+
+				if err != nil {
+					continue
+				}
+
+				var s = &testSession{
+					ch:      ch,
+					sizes:   make(chan string, 1),
+					signals: make(chan string, 1),
+				}
+
+				go s.handle(reqs)
+			}
+		}()
+	}
+}
+
+// dial an in-process SSH server on loopback
+func dial(t *testing.T) *ssh.Client {
+	var _, key, err = ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Expected no error generating key, got %v instead", err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+
+	if err != nil {
+		t.Fatalf("Expected no error creating signer, got %v instead", err)
+	}
+
+	var config = &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "henvic" && string(pass) == "secret" {
+				return nil, nil
+			}
+
+			return nil, fmt.Errorf("password rejected for %q", c.User())
+		},
+	}
+
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Expected no error listening, got %v instead", err)
+	}
+
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	go serve(l, config)
+
+	client, err := ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+		User:            "henvic",
+		Auth:            []ssh.AuthMethod{ssh.Password("secret")},
+		HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+	})
+
+	if err != nil {
+		t.Fatalf("Expected no error dialing, got %v instead", err)
+	}
+
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
+func TestSessionWithStory(t *testing.T) {
+	for _, command := range []string{"greet", ""} {
+		var term = &pseudoterm.Terminal{
+			Transport: &Session{
+				Client:  dial(t),
+				Command: command,
+			},
+		}
+
+		var story = &pseudoterm.QueueStory{
+			Timeout: 5 * time.Second,
+		}
+
+		story.Add(
+			pseudoterm.Step{
+				Read:  "Your name:",
+				Write: "Henrique",
+			},
+			pseudoterm.Step{
+				Read:      "Hi, Henrique",
+				SkipWrite: true,
+			})
+
+		if err := term.Run(story); err != nil {
+			t.Errorf("Expected no error during run, got %v instead", err)
+		}
+
+		if !story.Success() {
+			t.Errorf("Story didn't success.")
+		}
+
+		term.Wait()
+
+		if code := term.ExitCode(); code != 3 {
+			t.Errorf("Expected exit code 3, got %v instead", code)
+		}
+	}
+}
+
+func TestSessionSize(t *testing.T) {
+	var term = &pseudoterm.Terminal{
+		Transport: &Session{
+			Client:  dial(t),
+			Command: "size",
+			Term:    "vt100",
+		},
+		Rows: 30,
+		Cols: 100,
+	}
+
+	var story = &pseudoterm.QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(
+		pseudoterm.Step{
+			ReadFunc: func(in string) bool {
+				if in != "vt100 30x100\r\n" {
+					return false
+				}
+
+				if err := term.SetSize(40, 120); err != nil {
+					t.Errorf("Expected no error resizing, got %v instead", err)
+				}
+
+				return true
+			},
+			SkipWrite: true,
+		},
+		pseudoterm.Step{
+			Read:      "40x120",
+			SkipWrite: true,
+		})
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success: %+v", story.Sequence)
+	}
+}
+
+func TestSessionSignal(t *testing.T) {
+	var term = &pseudoterm.Terminal{
+		Transport: &Session{
+			Client:  dial(t),
+			Command: "signal",
+		},
+	}
+
+	var story = &pseudoterm.QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(pseudoterm.Step{
+		ReadFunc: func(in string) bool {
+			if err := term.Signal(syscall.SIGINT); err != nil {
+				t.Errorf("Expected no error sending signal, got %v instead", err)
+			}
+
+			return true
+		},
+		SkipWrite: true,
+	})
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	term.Wait()
+
+	if code := term.ExitCode(); code != 130 {
+		t.Errorf("Expected exit code 130, got %v instead", code)
+	}
+
+	if err := term.Signal(syscall.Signal(100)); err == nil || err.Error() != "Unsupported signal signal 100" {
+		t.Errorf("Expected unsupported signal error, got %v instead", err)
+	}
+}
+
+func TestSessionExitMissing(t *testing.T) {
+	var term = &pseudoterm.Terminal{
+		Transport: &Session{
+			Client:  dial(t),
+			Command: "vanish",
+		},
+	}
+
+	var story = &pseudoterm.QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	var err = term.Run(story)
+	var ee, ok = err.(pseudoterm.ExecutionError)
+
+	if !ok {
+		t.Fatalf("Expected execution error, got %v instead", err)
+	}
+
+	if _, ok := ee.ExitError.(*ssh.ExitMissingError); !ok || ee.RunError != nil || ee.SigtermError != nil {
+		t.Errorf("Expected exit missing error, got %v instead", err)
+	}
+
+	if code := term.ExitCode(); code != -1 {
+		t.Errorf("Expected exit code -1, got %v instead", code)
+	}
+}
+
+func TestSessionMissingClient(t *testing.T) {
+	var term = &pseudoterm.Terminal{
+		Transport: &Session{},
+	}
+
+	if err := term.Start(); err == nil || err.Error() != "Missing SSH client" {
+		t.Errorf(`Unexpected error %v, wanted "Missing SSH client" instead`, err)
+	}
+}
@@ -0,0 +1,188 @@
+//go:build !windows
+// +build !windows
+
+package pseudoterm
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTerminalWithAlreadyStartedStory(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var term = &Terminal{
+		Command:    exec.Command("mocks/mock.sh"),
+		EchoStream: echoStream,
+	}
+
+	var story = &QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(Step{
+		Read:      "Starting",
+		SkipWrite: true,
+	},
+		Step{
+			Read:  "Your name:",
+			Write: "Henrique",
+		},
+		Step{
+			Read:  "Your age:",
+			Write: "10",
+		})
+
+	_, _ = story.Setup()
+
+	if err := term.Start(); err != nil {
+		t.Errorf("Expected no error during start, got %v instead", err)
+	}
+
+	if err := term.Watch(story); err != errAlreadyInitialized {
+		t.Errorf("Expected error %v during run, got %v instead", errAlreadyInitialized, err)
+	}
+}
+
+func TestStoryTimeout(t *testing.T) {
+	var story = &QueueStory{
+		Timeout: 10 * time.Millisecond,
+	}
+
+	var sequence = []Step{
+		Step{
+			Read:  "Select from 1..2:",
+			Write: "2",
+		},
+		Step{
+			Read:    "Project:",
+			Write:   "test",
+			Timeout: time.Second,
+		},
+	}
+
+	story.Add(sequence...)
+
+	var ctx, err = story.Setup()
+
+	if err != nil {
+		t.Errorf("Expected story setup to be fine, got %v error instead", err)
+	}
+
+	if !reflect.DeepEqual(story.Sequence, sequence) {
+		t.Errorf("Expected story sequence to be equal passed value")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	select {
+	case <-ctx.Done():
+		if ctx.Err() != context.DeadlineExceeded {
+			t.Errorf("Expected context error to be %v, got %v instead",
+				context.DeadlineExceeded,
+				ctx.Err())
+		}
+	default:
+		t.Errorf("Expected context error due to story timeout, got %v instead", ctx.Err())
+	}
+
+	if _, err := story.Setup(); err != errAlreadyInitialized {
+		t.Errorf("Wanted multiple initialization error to be %v, got %v instead",
+			errAlreadyInitialized,
+			err)
+	}
+}
+
+func TestStepTimeout(t *testing.T) {
+	var story = &QueueStory{}
+
+	var sequence = []Step{
+		Step{
+			Read:    "Select from 1..2:",
+			Write:   "2",
+			Timeout: 10 * time.Millisecond,
+		},
+		Step{
+			Read:  "Project:",
+			Write: "test",
+		},
+	}
+
+	story.Add(sequence...)
+
+	var _, err = story.Setup()
+
+	if err != nil {
+		t.Errorf("Expected story setup to be fine, got %v error instead", err)
+	}
+
+	if !reflect.DeepEqual(story.Sequence, sequence) {
+		t.Errorf("Expected story sequence to be equal passed value")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	var wantErr = `Timed out while waiting for line "Select from 1..2:": timeout 10ms`
+
+	if err := story.TickHandler(); err == nil ||
+		err.Error() != wantErr {
+		t.Errorf("Wanted err to be %v, got %v instead", wantErr, err)
+	}
+
+	select {
+	case <-story.ctx.Done():
+		if story.ctx.Err() != context.DeadlineExceeded {
+			t.Errorf("Expected context error to be %v, got %v instead",
+				context.DeadlineExceeded,
+				story.ctx.Err())
+		}
+	default:
+		t.Errorf("Expected context error due to timeout, got %v instead", story.ctx.Err())
+	}
+
+	if _, err := story.Setup(); err != errAlreadyInitialized {
+		t.Errorf("Wanted multiple initialization error to be %v, got %v instead",
+			errAlreadyInitialized,
+			err)
+	}
+}
+
+func TestStoryAddAndInternalShift(t *testing.T) {
+	var story = &QueueStory{}
+	var addStep = Step{
+		Read: "foo",
+	}
+
+	if _, err := story.Setup(); err != nil {
+		t.Errorf("Error trying to setup story: %v", err)
+	}
+
+	story.Add(addStep)
+
+	var step = story.shift()
+
+	if len(story.Sequence) != 0 {
+		t.Errorf("Expected sequence to have length 0, got %v instead", len(story.Sequence))
+	}
+
+	if step.Read != "foo" {
+		t.Errorf("Wrong value on step")
+	}
+
+	var stepDummy = story.shift()
+
+	if stepDummy.Read != "" {
+		t.Errorf("Expected step to be dummy, got %+v instead", stepDummy)
+	}
+
+	if len(story.Sequence) != 0 {
+		t.Errorf("Expected sequence to have length 0, got %v instead", len(story.Sequence))
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success.")
+	}
+}
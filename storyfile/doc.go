@@ -0,0 +1,42 @@
+/*
+Package storyfile loads pseudoterm stories from YAML or JSON files,
+so scenarios can be written without Go code.
+
+A story file looks like this:
+
+	command: ./mock.sh     # program to run (required)
+	args: ["--verbose"]    # arguments
+	env:                   # added to the environment of the current process
+	  LANG: C
+	dir: .                 # working directory, relative to the story file
+	timeout: 5s            # QueueStory Timeout
+	separate_stderr: false # Terminal SeparateStderr
+	steps:
+	  - read: Starting     # Step Read
+	    skip_write: true   # Step SkipWrite
+	  - read: "Your name:"
+	    write: Henrique    # Step Write
+	  - regex: "p([a-z]+)ch" # Step ReadRegex
+	    write: ok
+	    timeout: 1s        # Step Timeout
+	  - read: "Choose a region:"
+	    keys: [down, enter] # Step Keys (see pseudoterm.Keys for names)
+	  - read: "Error: not found"
+	    stream: stderr     # Step Stream: any (default), stdout or stderr
+	    skip_write: true
+	  - no_echo: true      # Step NoEcho: matches when the echo is disabled
+	    write: hunter2
+	    secret: true       # Step Secret: scrubs the write from the echo, logs and errors
+	  - input_requested: true # Step InputRequested: matches when the program waits for input (Linux only)
+	    write: done
+	  - foreground: bash   # Step Foreground: matches when bash owns the terminal again (Linux only)
+	    write: exit
+
+Each step must have either read or regex, or no_echo, input_requested or foreground. Durations use the time.ParseDuration format.
+JSON files use the same fields.
+
+Validation errors point to the offending line and column, like
+
+	story.yaml:12:5: unknown step field "wirte"
+*/
+package storyfile
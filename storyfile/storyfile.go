@@ -0,0 +1,523 @@
+package storyfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+	"gopkg.in/yaml.v3"
+)
+
+// File is a story file
+type File struct {
+	Filename       string
+	Command        string
+	Args           []string
+	Env            map[string]string
+	Dir            string
+	Timeout        time.Duration
+	SeparateStderr bool
+	Steps          []Step
+}
+
+// Step of a story file
+type Step struct {
+	Read           string
+	Regex          *regexp.Regexp
+	Stream         pseudoterm.OutputStream
+	NoEcho         bool
+	InputRequested bool
+	Foreground     string
+	Write          string
+	Secret         bool
+	Keys           []string
+	SkipWrite      bool
+	Timeout        time.Duration
+}
+
+// Error in a story file, pointing to where it happened
+type Error struct {
+	Filename string
+	Line     int
+	Column   int
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Filename, e.Line, e.Column, e.Msg)
+}
+
+var yamlErrorRegex = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// Load a story file
+func Load(filename string) (*File, error) {
+	var data, err = os.ReadFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(filename, data)
+}
+
+// Parse the content of a story file. The filename is used to resolve
+// its working directory and on error messages.
+func Parse(filename string, data []byte) (*File, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		if m := yamlErrorRegex.FindStringSubmatch(err.Error()); m != nil {
+			var line, _ = strconv.Atoi(m[1])
+			return nil, &Error{Filename: filename, Line: line, Column: 1, Msg: m[2]}
+		}
+
+		return nil, err
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, &Error{Filename: filename, Line: 1, Column: 1, Msg: "empty story file"}
+	}
+
+	var p = &parser{
+		filename: filename,
+	}
+
+	return p.file(doc.Content[0])
+}
+
+type parser struct {
+	filename string
+}
+
+func (p *parser) errorf(n *yaml.Node, format string, a ...interface{}) error {
+	return &Error{
+		Filename: p.filename,
+		Line:     n.Line,
+		Column:   n.Column,
+		Msg:      fmt.Sprintf(format, a...),
+	}
+}
+
+// fields calls fn for each key and value of a mapping node
+func (p *parser) fields(n *yaml.Node, what string, fn func(k, v *yaml.Node) error) error {
+	if n.Kind != yaml.MappingNode {
+		return p.errorf(n, "%s must be a mapping", what)
+	}
+
+	var seen = map[string]bool{}
+
+	for c := 0; c+1 < len(n.Content); c += 2 {
+		var k, v = n.Content[c], n.Content[c+1]
+
+		if seen[k.Value] {
+			return p.errorf(k, "duplicated %s field %q", what, k.Value)
+		}
+
+		seen[k.Value] = true
+
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) file(n *yaml.Node) (*File, error) {
+	var f = &File{
+		Filename: p.filename,
+	}
+
+	var err = p.fields(n, "story", func(k, v *yaml.Node) (err error) {
+		switch k.Value {
+		case "command":
+			f.Command, err = p.str(v, k.Value)
+		case "args":
+			f.Args, err = p.strs(v, k.Value)
+		case "env":
+			f.Env, err = p.env(v)
+		case "dir":
+			f.Dir, err = p.str(v, k.Value)
+		case "timeout":
+			f.Timeout, err = p.duration(v, k.Value)
+		case "separate_stderr":
+			err = p.bool(v, k.Value, &f.SeparateStderr)
+		case "steps":
+			f.Steps, err = p.steps(v)
+		default:
+			err = p.errorf(k, "unknown story field %q", k.Value)
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Command == "" {
+		return nil, p.errorf(n, "missing command")
+	}
+
+	return f, nil
+}
+
+func (p *parser) steps(n *yaml.Node) ([]Step, error) {
+	if n.Kind != yaml.SequenceNode {
+		return nil, p.errorf(n, "steps must be a list")
+	}
+
+	var steps = make([]Step, 0, len(n.Content))
+
+	for _, sn := range n.Content {
+		s, err := p.step(sn)
+
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, s)
+	}
+
+	return steps, nil
+}
+
+func (p *parser) step(n *yaml.Node) (s Step, err error) {
+	var hasRead, hasWrite bool
+
+	err = p.fields(n, "step", func(k, v *yaml.Node) (err error) {
+		switch k.Value {
+		case "read":
+			hasRead = true
+			s.Read, err = p.str(v, k.Value)
+		case "regex":
+			s.Regex, err = p.regex(v)
+		case "stream":
+			s.Stream, err = p.stream(v)
+		case "no_echo":
+			err = p.bool(v, k.Value, &s.NoEcho)
+		case "input_requested":
+			err = p.bool(v, k.Value, &s.InputRequested)
+		case "foreground":
+			s.Foreground, err = p.str(v, k.Value)
+		case "write":
+			hasWrite = true
+			s.Write, err = p.str(v, k.Value)
+		case "secret":
+			err = p.bool(v, k.Value, &s.Secret)
+		case "keys":
+			s.Keys, err = p.keys(v)
+		case "skip_write":
+			err = p.bool(v, k.Value, &s.SkipWrite)
+		case "timeout":
+			s.Timeout, err = p.duration(v, k.Value)
+		default:
+			err = p.errorf(k, "unknown step field %q", k.Value)
+		}
+
+		return err
+	})
+
+	switch {
+	case err != nil:
+		return s, err
+	case hasRead && s.Regex != nil:
+		return s, p.errorf(n, "step must have either read or regex, not both")
+	case !hasRead && s.Regex == nil && !s.NoEcho && !s.InputRequested && s.Foreground == "":
+		return s, p.errorf(n, "step must have read, regex, no_echo, input_requested or foreground")
+	case s.SkipWrite && (hasWrite || len(s.Keys) != 0):
+		return s, p.errorf(n, "step with skip_write can't have write or keys")
+	case s.Secret && (s.SkipWrite || len(s.Keys) != 0):
+		return s, p.errorf(n, "step with secret can't have skip_write or keys")
+	}
+
+	return s, nil
+}
+
+func (p *parser) str(n *yaml.Node, what string) (string, error) {
+	if n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+		return "", p.errorf(n, "%s must be a string", what)
+	}
+
+	return n.Value, nil
+}
+
+func (p *parser) strs(n *yaml.Node, what string) ([]string, error) {
+	if n.Kind != yaml.SequenceNode {
+		return nil, p.errorf(n, "%s must be a list of strings", what)
+	}
+
+	var list = make([]string, 0, len(n.Content))
+
+	for _, i := range n.Content {
+		s, err := p.str(i, what)
+
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, s)
+	}
+
+	return list, nil
+}
+
+func (p *parser) bool(n *yaml.Node, what string, b *bool) error {
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!bool" || n.Decode(b) != nil {
+		return p.errorf(n, "%s must be true or false", what)
+	}
+
+	return nil
+}
+
+func (p *parser) duration(n *yaml.Node, what string) (time.Duration, error) {
+	var s, err = p.str(n, what)
+
+	if err != nil {
+		return 0, err
+	}
+
+	d, err := time.ParseDuration(s)
+
+	if err != nil || d < 0 {
+		return 0, p.errorf(n, "%s must be a duration such as \"5s\", got %q", what, s)
+	}
+
+	return d, nil
+}
+
+func (p *parser) regex(n *yaml.Node) (*regexp.Regexp, error) {
+	var s, err = p.str(n, "regex")
+
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(s)
+
+	if err != nil {
+		return nil, p.errorf(n, "invalid regex: %v", err)
+	}
+
+	return re, nil
+}
+
+var streams = map[string]pseudoterm.OutputStream{
+	"any":    pseudoterm.AnyStream,
+	"stdout": pseudoterm.StdoutStream,
+	"stderr": pseudoterm.StderrStream,
+}
+
+func (p *parser) stream(n *yaml.Node) (pseudoterm.OutputStream, error) {
+	var s, err = p.str(n, "stream")
+
+	if err != nil {
+		return pseudoterm.AnyStream, err
+	}
+
+	stream, ok := streams[s]
+
+	if !ok {
+		return pseudoterm.AnyStream, p.errorf(n, "stream must be any, stdout or stderr, got %q", s)
+	}
+
+	return stream, nil
+}
+
+func (p *parser) keys(n *yaml.Node) ([]string, error) {
+	var keys, err = p.strs(n, "keys")
+
+	if err != nil {
+		return nil, err
+	}
+
+	for c, k := range keys {
+		if _, ok := pseudoterm.Keys[k]; !ok {
+			return nil, p.errorf(n.Content[c], "unknown key %q", k)
+		}
+	}
+
+	return keys, nil
+}
+
+func (p *parser) env(n *yaml.Node) (map[string]string, error) {
+	var env = map[string]string{}
+
+	var err = p.fields(n, "env", func(k, v *yaml.Node) (err error) {
+		env[k.Value], err = p.str(v, "env value")
+		return err
+	})
+
+	return env, err
+}
+
+// Terminal for running the story command
+func (f *File) Terminal() *pseudoterm.Terminal {
+	var cmd = exec.Command(f.Command, f.Args...)
+
+	cmd.Dir = f.Dir
+
+	if !filepath.IsAbs(cmd.Dir) {
+		cmd.Dir = filepath.Join(filepath.Dir(f.Filename), cmd.Dir)
+	}
+
+	if len(f.Env) != 0 {
+		var keys = make([]string, 0, len(f.Env))
+
+		for k := range f.Env {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		cmd.Env = os.Environ()
+
+		for _, k := range keys {
+			cmd.Env = append(cmd.Env, k+"="+f.Env[k])
+		}
+	}
+
+	return &pseudoterm.Terminal{
+		Command:        cmd,
+		SeparateStderr: f.SeparateStderr,
+	}
+}
+
+// Story with the steps of the story file
+func (f *File) Story() *pseudoterm.QueueStory {
+	var q = &pseudoterm.QueueStory{
+		Timeout: f.Timeout,
+	}
+
+	for _, s := range f.Steps {
+		q.Add(pseudoterm.Step{
+			Read:           s.Read,
+			ReadRegex:      s.Regex,
+			Stream:         s.Stream,
+			NoEcho:         s.NoEcho,
+			InputRequested: s.InputRequested,
+			Foreground:     s.Foreground,
+			Write:          s.Write,
+			Secret:         s.Secret,
+			Keys:           s.Keys,
+			SkipWrite:      s.SkipWrite,
+			Timeout:        s.Timeout,
+		})
+	}
+
+	return q
+}
+
+// FromTranscript creates a story file running the given command
+// with the steps inferred from a transcript
+func FromTranscript(tr *pseudoterm.Transcript, command string, args ...string) *File {
+	var f = &File{
+		Command: command,
+		Args:    args,
+		Timeout: 5 * time.Second,
+	}
+
+	for _, s := range tr.Steps() {
+		f.Steps = append(f.Steps, Step{
+			Read:   s.Read,
+			NoEcho: s.NoEcho,
+			Write:  s.Write,
+			Secret: s.Secret,
+		})
+	}
+
+	return f
+}
+
+type fileYAML struct {
+	Command        string            `yaml:"command"`
+	Args           []string          `yaml:"args,omitempty"`
+	Env            map[string]string `yaml:"env,omitempty"`
+	Dir            string            `yaml:"dir,omitempty"`
+	Timeout        string            `yaml:"timeout,omitempty"`
+	SeparateStderr bool              `yaml:"separate_stderr,omitempty"`
+	Steps          []stepYAML        `yaml:"steps"`
+}
+
+type stepYAML struct {
+	Read           *string  `yaml:"read,omitempty"`
+	Regex          string   `yaml:"regex,omitempty"`
+	Stream         string   `yaml:"stream,omitempty"`
+	NoEcho         bool     `yaml:"no_echo,omitempty"`
+	InputRequested bool     `yaml:"input_requested,omitempty"`
+	Foreground     string   `yaml:"foreground,omitempty"`
+	Write          string   `yaml:"write,omitempty"`
+	Secret         bool     `yaml:"secret,omitempty"`
+	Keys           []string `yaml:"keys,omitempty,flow"`
+	SkipWrite      bool     `yaml:"skip_write,omitempty"`
+	Timeout        string   `yaml:"timeout,omitempty"`
+}
+
+// WriteYAML encodes the story file as YAML
+func (f *File) WriteYAML(w io.Writer) error {
+	var y = fileYAML{
+		Command:        f.Command,
+		Args:           f.Args,
+		Env:            f.Env,
+		Dir:            f.Dir,
+		Timeout:        durationString(f.Timeout),
+		SeparateStderr: f.SeparateStderr,
+		Steps:          []stepYAML{},
+	}
+
+	for _, s := range f.Steps {
+		var sy = stepYAML{
+			Stream:         streamString(s.Stream),
+			NoEcho:         s.NoEcho,
+			InputRequested: s.InputRequested,
+			Foreground:     s.Foreground,
+			Write:          s.Write,
+			Secret:         s.Secret,
+			Keys:           s.Keys,
+			SkipWrite:      s.SkipWrite,
+			Timeout:        durationString(s.Timeout),
+		}
+
+		if s.Regex != nil {
+			sy.Regex = s.Regex.String()
+		} else if s.Read != "" || (!s.NoEcho && !s.InputRequested && s.Foreground == "") {
+			var read = s.Read
+			sy.Read = &read
+		}
+
+		y.Steps = append(y.Steps, sy)
+	}
+
+	var e = yaml.NewEncoder(w)
+	e.SetIndent(2)
+
+	if err := e.Encode(y); err != nil {
+		return err
+	}
+
+	return e.Close()
+}
+
+func streamString(stream pseudoterm.OutputStream) string {
+	for k, v := range streams {
+		if v == stream && v != pseudoterm.AnyStream {
+			return k
+		}
+	}
+
+	return ""
+}
+
+func durationString(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+
+	return d.String()
+}
@@ -0,0 +1,220 @@
+//go:build !windows
+// +build !windows
+
+package storyfile
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+)
+
+func TestLoadAndRun(t *testing.T) {
+	for _, filename := range []string{"testdata/mock.yaml", "testdata/mock.json"} {
+		var f, err = Load(filename)
+
+		if err != nil {
+			t.Fatalf("Expected no error loading %v, got %v instead", filename, err)
+		}
+
+		if f.Timeout != 5*time.Second || len(f.Steps) != 3 || f.Steps[2].Timeout != time.Second {
+			t.Errorf("Unexpected story file %+v", f)
+		}
+
+		var echoStream = &bytes.Buffer{}
+		var term = f.Terminal()
+		term.EchoStream = echoStream
+
+		var story = f.Story()
+
+		if err := term.Run(story); err != nil {
+			t.Errorf("Expected no error during run, got %v instead", err)
+		}
+
+		if !story.Success() {
+			t.Errorf("Story %v didn't success.", filename)
+		}
+
+		if !strings.Contains(echoStream.String(), "Your age is 10") {
+			t.Errorf("Unexpected output %q", echoStream.String())
+		}
+	}
+}
+
+func TestSeparateStderr(t *testing.T) {
+	var f, err = Load("testdata/stderr.yaml")
+
+	if err != nil {
+		t.Fatalf("Expected no error loading, got %v instead", err)
+	}
+
+	var stderr = &bytes.Buffer{}
+	var term = f.Terminal()
+	term.StderrEchoStream = stderr
+
+	var story = f.Story()
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success: %+v", story.Sequence)
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	if want := "Warning: no config\nError: Henrique not found\n"; stderr.String() != want {
+		t.Errorf("Expected stderr to be %q, got %q instead", want, stderr.String())
+	}
+
+	var b bytes.Buffer
+
+	if err := f.WriteYAML(&b); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if !strings.Contains(b.String(), "separate_stderr: true\n") ||
+		!strings.Contains(b.String(), "stream: stderr\n") ||
+		!strings.Contains(b.String(), "stream: stdout\n") {
+		t.Errorf("Expected YAML to contain stderr options, got:\n%s", b.String())
+	}
+}
+
+func TestNoEcho(t *testing.T) {
+	var f, err = Load("testdata/password.yaml")
+
+	if err != nil {
+		t.Fatalf("Expected no error loading, got %v instead", err)
+	}
+
+	var story = f.Story()
+
+	if err := f.Terminal().Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success: %+v", story.Sequence)
+	}
+
+	var b bytes.Buffer
+
+	if err := f.WriteYAML(&b); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if want := "  - no_echo: true\n    write: hunter2\n    secret: true\n"; !strings.Contains(b.String(), want) {
+		t.Errorf("Expected YAML to contain %q, got:\n%s", want, b.String())
+	}
+}
+
+func TestTerminalEnv(t *testing.T) {
+	var f = &File{
+		Filename: "stories/foo.yaml",
+		Command:  "env",
+		Env: map[string]string{
+			"B": "2",
+			"A": "1",
+		},
+	}
+
+	var cmd = f.Terminal().Command
+
+	if cmd.Dir != "stories" {
+		t.Errorf("Expected dir to be relative to story file, got %v instead", cmd.Dir)
+	}
+
+	if env := cmd.Env[len(cmd.Env)-2:]; !reflect.DeepEqual(env, []string{"A=1", "B=2"}) {
+		t.Errorf("Expected env to be appended in order, got %v instead", env)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var cases = []struct {
+		content string
+		err     string
+	}{
+		{"", "story.yaml:1:1: empty story file"},
+		{"command: [", "story.yaml:1:1: did not find expected node content"},
+		{"- foo", "story.yaml:1:1: story must be a mapping"},
+		{"steps: []", "story.yaml:1:1: missing command"},
+		{"command: x\ncomand: y", `story.yaml:2:1: unknown story field "comand"`},
+		{"command: x\ncommand: y", `story.yaml:2:1: duplicated story field "command"`},
+		{"command: [x]", "story.yaml:1:10: command must be a string"},
+		{"command: x\nargs: x", "story.yaml:2:7: args must be a list of strings"},
+		{"command: x\nenv: [x]", "story.yaml:2:6: env must be a mapping"},
+		{"command: x\ntimeout: 5", `story.yaml:2:10: timeout must be a duration such as "5s", got "5"`},
+		{"command: x\nsteps:\n  - read: a\n    wirte: b", `story.yaml:4:5: unknown step field "wirte"`},
+		{"command: x\nsteps:\n  - write: b", "story.yaml:3:5: step must have read, regex, no_echo, input_requested or foreground"},
+		{"command: x\nsteps:\n  - read: a\n    regex: b", "story.yaml:3:5: step must have either read or regex, not both"},
+		{"command: x\nsteps:\n  - regex: \"(\"", "story.yaml:3:12: invalid regex: error parsing regexp: missing closing ): `(`"},
+		{"command: x\nsteps:\n  - read: a\n    keys: [up, hyper]", `story.yaml:4:16: unknown key "hyper"`},
+		{"command: x\nsteps:\n  - read: a\n    skip_write: yes", "story.yaml:4:17: skip_write must be true or false"},
+		{"command: x\nsteps:\n  - read: a\n    skip_write: true\n    write: b", "story.yaml:3:5: step with skip_write can't have write or keys"},
+		{"command: x\nsteps: {}", "story.yaml:2:8: steps must be a list"},
+		{"command: x\nsteps:\n  - no_echo: 1", "story.yaml:3:14: no_echo must be true or false"},
+		{"command: x\nsteps:\n  - input_requested: no", "story.yaml:3:22: input_requested must be true or false"},
+		{"command: x\nsteps:\n  - foreground: [bash]", "story.yaml:3:17: foreground must be a string"},
+		{"command: x\nsteps:\n  - read: a\n    secret: true\n    keys: [enter]", "story.yaml:3:5: step with secret can't have skip_write or keys"},
+		{"command: x\nseparate_stderr: 1", "story.yaml:2:18: separate_stderr must be true or false"},
+		{"command: x\nsteps:\n  - read: a\n    stream: err", `story.yaml:4:13: stream must be any, stdout or stderr, got "err"`},
+	}
+
+	for _, c := range cases {
+		var _, err = Parse("story.yaml", []byte(c.content))
+
+		if err == nil || err.Error() != c.err {
+			t.Errorf("Expected error parsing %q to be %v, got %v instead", c.content, c.err, err)
+		}
+	}
+}
+
+func TestFromTranscriptWriteYAML(t *testing.T) {
+	var tr = &pseudoterm.Transcript{
+		Entries: []pseudoterm.Entry{
+			pseudoterm.Entry{
+				Output: "Starting\r\nYour name: ",
+				Input:  "Henrique",
+			},
+			pseudoterm.Entry{
+				Output: "Your age: ",
+				Input:  "10",
+			},
+		},
+	}
+
+	var b bytes.Buffer
+
+	if err := FromTranscript(tr, "./mock.sh").WriteYAML(&b); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	var want = `command: ./mock.sh
+timeout: 5s
+steps:
+  - read: 'Your name:'
+    write: Henrique
+  - read: 'Your age:'
+    write: "10"
+`
+
+	if b.String() != want {
+		t.Errorf("Expected YAML to be:\n%s\ngot:\n%s", want, b.String())
+	}
+
+	var f, err = Parse("story.yaml", b.Bytes())
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if f.Steps[1].Read != "Your age:" || f.Steps[1].Write != "10" {
+		t.Errorf("Unexpected steps after parsing YAML: %+v", f.Steps)
+	}
+}
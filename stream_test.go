@@ -0,0 +1,109 @@
+//go:build !windows
+// +build !windows
+
+package pseudoterm_test
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+	"github.com/henvic/pseudoterm/pseudotermtest"
+)
+
+func TestTerminalWithSeparateStderr(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var stderrEchoStream = &bytes.Buffer{}
+	var term = &pseudoterm.Terminal{
+		Command:          exec.Command("mocks/mock-stderr.sh"),
+		EchoStream:       echoStream,
+		SeparateStderr:   true,
+		StderrEchoStream: stderrEchoStream,
+	}
+
+	var story = &pseudoterm.QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(
+		pseudoterm.Step{
+			Read:      "Warning: no config",
+			Stream:    pseudoterm.StderrStream,
+			SkipWrite: true,
+		},
+		pseudoterm.Step{
+			Read:   "Your name:",
+			Stream: pseudoterm.StdoutStream,
+			Write:  "Henrique",
+		},
+		pseudoterm.Step{
+			Read:      "Error: Henrique not found",
+			Stream:    pseudoterm.StderrStream,
+			SkipWrite: true,
+		})
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success: %+v", story.Sequence)
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	pseudotermtest.AssertSimilar(t, "Starting\nYour name: Henrique\nHi Henrique", echoStream.String())
+	pseudotermtest.AssertSimilar(t, "Warning: no config\nError: Henrique not found", stderrEchoStream.String())
+}
+
+func TestTerminalSeparateStderrUnsupported(t *testing.T) {
+	var term = &pseudoterm.Terminal{
+		Transport: &pseudoterm.Program{
+			Func: func(stdin io.Reader, stdout io.Writer) int {
+				return 0
+			},
+		},
+		SeparateStderr: true,
+	}
+
+	var err = term.Start()
+
+	if err == nil || err.Error() != "Transport doesn't support SeparateStderr" {
+		t.Errorf(`Unexpected error %v, wanted "Transport doesn't support SeparateStderr" instead`, err)
+	}
+}
+
+func TestStoryHandleStreamLine(t *testing.T) {
+	var story = &pseudoterm.QueueStory{}
+
+	story.Add(
+		pseudoterm.Step{
+			Read:   "oops",
+			Stream: pseudoterm.StderrStream,
+			Write:  "first",
+		},
+		pseudoterm.Step{
+			Read:  "oops",
+			Write: "second",
+		})
+
+	if _, err := story.HandleStreamLine("oops", pseudoterm.StdoutStream); err != pseudoterm.SkipWrite {
+		t.Errorf("Expected stdout line to be skipped, got %v instead", err)
+	}
+
+	if _, err := story.HandleLine("oops"); err != pseudoterm.SkipWrite {
+		t.Errorf("Expected line on unknown stream to be skipped, got %v instead", err)
+	}
+
+	if in, err := story.HandleStreamLine("oops", pseudoterm.StderrStream); in != "first" || err != nil {
+		t.Errorf("Expected stderr line to match, got (%v, %v) instead", in, err)
+	}
+
+	if in, err := story.HandleStreamLine("oops", pseudoterm.StdoutStream); in != "second" || err != nil {
+		t.Errorf("Expected line on any stream to match, got (%v, %v) instead", in, err)
+	}
+}
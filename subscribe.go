@@ -0,0 +1,218 @@
+package pseudoterm
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSlowSubscriber is returned by Subscription Err when it was disconnected
+// for not keeping up with the output
+var ErrSlowSubscriber = errors.New("Subscriber disconnected for not keeping up with the output")
+
+// SubscriberPolicy tells what happens to output chunks when the channel of a subscriber is full
+type SubscriberPolicy int
+
+const (
+	// SubscriberDrop drops chunks while the channel is full
+	SubscriberDrop SubscriberPolicy = iota
+
+	// SubscriberBlock stops copying the output until the chunk is received,
+	// slowing down the program and every other consumer
+	SubscriberBlock
+
+	// SubscriberDisconnect closes the subscription
+	SubscriberDisconnect
+)
+
+// Chunk of output printed by the program, with the secrets redacted.
+// Stream is AnyStream unless the Terminal uses SeparateStderr.
+type Chunk struct {
+	Stream OutputStream
+	Data   []byte
+}
+
+// Subscription to the output of a Terminal.
+// Receive from C or use it as an io.Reader, not both.
+// C is closed when the output ends or the subscription is closed.
+type Subscription struct {
+	C <-chan Chunk
+
+	t       *Terminal
+	c       chan Chunk
+	policy  SubscriberPolicy
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	closed  bool
+	err     error
+	dropped int64
+	unread  []byte
+}
+
+// Subscribe to the output of the program from now on.
+// Chunks are sent on a channel with the given buffer size,
+// and the policy tells what to do when it is full.
+// Subscriptions can be added before or after Start, and closed at any time.
+func (t *Terminal) Subscribe(size int, policy SubscriberPolicy) *Subscription {
+	var c = make(chan Chunk, size)
+	var s = &Subscription{
+		C:      c,
+		t:      t,
+		c:      c,
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+
+	t.subMu.Lock()
+	var ended = t.subEnded
+
+	if !ended {
+		t.subs = append(t.subs, s)
+	}
+
+	t.subMu.Unlock()
+
+	if ended {
+		s.Close()
+	}
+
+	return s
+}
+
+// Close the subscription
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked(nil)
+}
+
+// Err tells why the subscription was closed early, if it was
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Dropped is the number of bytes not delivered to the subscriber
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Read the output, ignoring streams
+func (s *Subscription) Read(p []byte) (n int, err error) {
+	for len(s.unread) == 0 {
+		var chunk, ok = <-s.C
+
+		if !ok {
+			return 0, io.EOF
+		}
+
+		s.unread = chunk.Data
+	}
+
+	n = copy(p, s.unread)
+	s.unread = s.unread[n:]
+	return n, nil
+}
+
+func (s *Subscription) send(chunk Chunk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	if s.policy == SubscriberBlock {
+		select {
+		case s.c <- chunk:
+		case <-s.done:
+		}
+
+		return
+	}
+
+	select {
+	case s.c <- chunk:
+	default:
+		atomic.AddInt64(&s.dropped, int64(len(chunk.Data)))
+
+		if s.policy == SubscriberDisconnect {
+			s.closeLocked(ErrSlowSubscriber)
+		}
+	}
+}
+
+func (s *Subscription) closeLocked(err error) {
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	s.err = err
+	close(s.c)
+	s.t.unsubscribe(s)
+}
+
+func (t *Terminal) unsubscribe(s *Subscription) {
+	t.subMu.Lock()
+	defer t.subMu.Unlock()
+
+	for c, sub := range t.subs {
+		if sub == s {
+			t.subs = append(t.subs[:c], t.subs[c+1:]...)
+			return
+		}
+	}
+}
+
+func (t *Terminal) publish(chunk Chunk) {
+	t.subMu.Lock()
+	var subs = append([]*Subscription{}, t.subs...)
+	t.subMu.Unlock()
+
+	for _, s := range subs {
+		s.send(chunk)
+	}
+}
+
+// endSubscriptions closes the subscriptions once the output ends
+func (t *Terminal) endSubscriptions() {
+	t.subMu.Lock()
+	var subs = t.subs
+	t.subs = nil
+	t.subEnded = true
+	t.subMu.Unlock()
+
+	for _, s := range subs {
+		s.Close()
+	}
+}
+
+// outputWriter writes the scrubbed output of the program to an echo stream and the subscribers
+type outputWriter struct {
+	t      *Terminal
+	w      io.Writer
+	stream OutputStream
+}
+
+func (o outputWriter) Write(p []byte) (n int, err error) {
+	if o.w != nil {
+		if n, err = o.w.Write(p); err != nil {
+			return n, err
+		}
+	}
+
+	o.t.publish(Chunk{
+		Stream: o.stream,
+		Data:   append([]byte{}, p...),
+	})
+
+	return len(p), nil
+}
@@ -0,0 +1,108 @@
+//go:build !windows
+// +build !windows
+
+package pseudoterm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var term = &Terminal{
+		Command:    exec.Command("mocks/mock.sh"),
+		EchoStream: echoStream,
+	}
+
+	var first = term.Subscribe(100, SubscriberBlock)
+	var second = term.Subscribe(100, SubscriberBlock)
+	var outputs = make(chan string, 2)
+
+	for _, s := range []*Subscription{first, second} {
+		go func(s *Subscription) {
+			var b, _ = ioutil.ReadAll(s)
+			outputs <- string(b)
+		}(s)
+	}
+
+	var story = &QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(
+		Step{
+			Read:  "Your name:",
+			Write: "Henrique",
+		},
+		Step{
+			Read:  "Your age:",
+			Write: "10",
+		})
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	for c := 0; c < 2; c++ {
+		if got := <-outputs; got != echoStream.String() {
+			t.Errorf("Expected subscriber to receive %q, got %q instead", echoStream.String(), got)
+		}
+	}
+}
+
+func TestSubscribeSlowSubscriber(t *testing.T) {
+	var term = &Terminal{
+		Transport: &Program{
+			Func: func(stdin io.Reader, stdout io.Writer) int {
+				for c := 0; c < 10; c++ {
+					fmt.Fprintf(stdout, "line %d\n", c)
+					time.Sleep(time.Millisecond)
+				}
+
+				return 0
+			},
+		},
+	}
+
+	var drop = term.Subscribe(1, SubscriberDrop)
+	var disconnect = term.Subscribe(1, SubscriberDisconnect)
+	var closed = term.Subscribe(1, SubscriberBlock)
+	closed.Close()
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	if drop.Dropped() == 0 || drop.Err() != nil {
+		t.Errorf("Expected chunks to be dropped, got (%v, %v) instead", drop.Dropped(), drop.Err())
+	}
+
+	if chunk := <-drop.C; !strings.HasPrefix(string(chunk.Data), "line 0") || chunk.Stream != AnyStream {
+		t.Errorf("Expected first chunk to be kept, got %+v instead", chunk)
+	}
+
+	if disconnect.Err() != ErrSlowSubscriber {
+		t.Errorf("Expected error to be %v, got %v instead", ErrSlowSubscriber, disconnect.Err())
+	}
+
+	if _, ok := <-closed.C; ok {
+		t.Errorf("Expected closed subscription to not receive chunks")
+	}
+
+	if _, ok := <-term.Subscribe(1, SubscriberDrop).C; ok {
+		t.Errorf("Expected subscription after the output ended to be closed")
+	}
+}
@@ -0,0 +1,203 @@
+package pseudoterm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// Mode of the pseudo tty, as set by the program
+type Mode struct {
+	// Echo tells if the input is echoed, what programs usually disable when asking for passwords
+	Echo bool
+
+	// Canonical tells if the input is handled line by line
+	Canonical bool
+
+	// Raw tells if the canonical mode, echo and signal characters are disabled,
+	// as in full-screen programs
+	Raw bool
+}
+
+// Foreground process group of the terminal, the one receiving its input.
+// It changes when a shell runs a command, and back when the command finishes.
+type Foreground struct {
+	// Pgid of the process group
+	Pgid int
+
+	// Program name of the process group leader, without its path
+	Program string
+
+	// Command line of the process group leader, with the arguments separated by spaces
+	Command string
+}
+
+// State of the terminal.
+// InputRequested tells if the program is blocked waiting for input from the
+// terminal. InputRequested and Foreground are only supported on Linux.
+type State struct {
+	Mode           Mode
+	InputRequested bool
+	Foreground     Foreground
+}
+
+// StateStory is implemented by stories handling the state of the terminal.
+// HandleState is called on every tick while watching, with the current state,
+// if NeedsState returns true (querying the state isn't free).
+type StateStory interface {
+	Story
+	NeedsState() bool
+	HandleState(s State) (in string, err error)
+}
+
+// moder is implemented by transports that can tell the mode of the tty
+type moder interface {
+	Mode() (Mode, error)
+}
+
+// inputRequester is implemented by transports that can tell if the program
+// is waiting for input
+type inputRequester interface {
+	InputRequested() (bool, error)
+}
+
+// foregrounder is implemented by transports that can tell the foreground
+// process group of the tty
+type foregrounder interface {
+	Foreground() (Foreground, error)
+}
+
+var errNotStarted = errors.New("Not started")
+
+// State of the terminal.
+// Transports other than the pseudo tty might not support it.
+func (t *Terminal) State() (s State, err error) {
+	if t.transport == nil {
+		return s, errNotStarted
+	}
+
+	m, ok := t.transport.(moder)
+
+	if !ok {
+		return s, ErrUnsupported
+	}
+
+	if s.Mode, err = m.Mode(); err != nil {
+		return s, err
+	}
+
+	if ir, ok := t.transport.(inputRequester); ok {
+		if s.InputRequested, err = ir.InputRequested(); err != nil && err != ErrUnsupported {
+			return s, err
+		}
+	}
+
+	if f, ok := t.transport.(foregrounder); ok {
+		if s.Foreground, err = f.Foreground(); err != nil && err != ErrUnsupported {
+			return s, err
+		}
+	}
+
+	return s, nil
+}
+
+// WaitForInputRequest waits until the program is blocked waiting for input
+// from the terminal, what is a robust signal that a prompt is ready.
+// It is only supported on Linux.
+func (t *Terminal) WaitForInputRequest(ctx context.Context) error {
+	for {
+		if t.ended() {
+			return errors.New("Program has ended")
+		}
+
+		if ir, ok := t.transport.(inputRequester); !ok {
+			return ErrUnsupported
+		} else if requested, err := ir.InputRequested(); err != nil {
+			return err
+		} else if requested {
+			return nil
+		}
+
+		var timer = t.clock().NewTimer(LineReaderInterval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
+
+// NotifyState relays changes of the terminal state to the channel while watching.
+// Like signal.Notify, it doesn't block sending to the channel:
+// make sure it has enough buffer space.
+func (t *Terminal) NotifyState(c chan<- State) {
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+	t.stateChans = append(t.stateChans, c)
+}
+
+func (t *Terminal) handleState(s Story) error {
+	var ss, ok = s.(StateStory)
+	var needed = ok && ss.NeedsState()
+
+	if !needed && !t.notifiesState() {
+		return nil
+	}
+
+	var state, err = t.State()
+
+	// the state is unavailable when unsupported or after the program ends
+	if err != nil {
+		return nil
+	}
+
+	t.notifyState(state)
+
+	if needed {
+		return t.handleInput(ss.HandleState(state))
+	}
+
+	return nil
+}
+
+func (t *Terminal) notifiesState() bool {
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+	return len(t.stateChans) != 0
+}
+
+func (t *Terminal) notifyState(state State) {
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+
+	if t.lastState != nil && *t.lastState == state {
+		return
+	}
+
+	t.lastState = &state
+
+	for _, c := range t.stateChans {
+		select {
+		case c <- state:
+		default:
+		}
+	}
+}
+
+// programName from the first argument of a command line,
+// without the path or the dash of login shells
+func programName(arg0 string) string {
+	if arg0 == "" {
+		return ""
+	}
+
+	return strings.TrimPrefix(filepath.Base(arg0), "-")
+}
+
+// is tells if the program name or the command line of the leader is the given name
+func (f Foreground) is(name string) bool {
+	return f.Program == name || f.Command == name
+}
@@ -0,0 +1,24 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package pseudoterm
+
+import "os"
+
+func getMode(fd uintptr) (m Mode, err error) {
+	return m, ErrUnsupported
+}
+
+func makeRaw(fd int) (restore func() error, err error) {
+	return nil, ErrUnsupported
+}
+
+func getSize(fd int) (rows, cols uint16, err error) {
+	return 0, 0, ErrUnsupported
+}
+
+func notifyResize(c chan<- os.Signal) {}
+
+func nonblockingDup(fd int) (f *os.File, restore func(), err error) {
+	return nil, nil, ErrUnsupported
+}
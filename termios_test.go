@@ -0,0 +1,193 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package pseudoterm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTerminalWithNoEchoStep(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var term = &Terminal{
+		Command:    exec.Command("mocks/mock-password.sh"),
+		EchoStream: echoStream,
+	}
+
+	var story = &QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(
+		Step{
+			Read:  "Your name:",
+			Write: "Henrique",
+		},
+		Step{
+			NoEcho: true,
+			Write:  "hunter2",
+		},
+		Step{
+			Read:      "Hello Henrique, your code has 7 characters",
+			SkipWrite: true,
+		})
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success: %+v", story.Sequence)
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	if strings.Contains(echoStream.String(), "hunter2") {
+		t.Errorf("Expected secret to not be echoed, got %q", echoStream.String())
+	}
+}
+
+func TestTerminalNotifyState(t *testing.T) {
+	var term = &Terminal{
+		Command: exec.Command("sh", "-c", "sleep 0.2; stty -echo; sleep 0.2; stty raw; sleep 0.2; stty sane; sleep 0.2"),
+	}
+
+	var states = make(chan State, 10)
+	term.NotifyState(states)
+
+	var story = &QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	var want = []Mode{
+		Mode{Echo: true, Canonical: true},
+		Mode{Canonical: true},
+		Mode{Raw: true},
+		Mode{Echo: true, Canonical: true},
+	}
+
+	close(states)
+	var got []Mode
+
+	// the state also changes when other fields than the mode change
+	for s := range states {
+		if len(got) == 0 || got[len(got)-1] != s.Mode {
+			got = append(got, s.Mode)
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Expected modes to be %+v, got %+v instead", want, got)
+	}
+
+	for c := range want {
+		if got[c] != want[c] {
+			t.Errorf("Expected modes to be %+v, got %+v instead", want, got)
+		}
+	}
+}
+
+func TestTerminalStateUnsupported(t *testing.T) {
+	var term = &Terminal{
+		Transport: &Program{
+			Func: func(stdin io.Reader, stdout io.Writer) int {
+				return 0
+			},
+		},
+	}
+
+	if _, err := term.State(); err != errNotStarted {
+		t.Errorf("Expected error to be %v, got %v instead", errNotStarted, err)
+	}
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if _, err := term.State(); err != ErrUnsupported {
+		t.Errorf("Expected error to be %v, got %v instead", ErrUnsupported, err)
+	}
+
+	term.Wait()
+}
+
+type moderProgram struct {
+	*Program
+	calls int32
+}
+
+func (m *moderProgram) Mode() (Mode, error) {
+	atomic.AddInt32(&m.calls, 1)
+	return Mode{Echo: true, Canonical: true}, nil
+}
+
+func TestTerminalStateOnlyWhenNeeded(t *testing.T) {
+	var transport = &moderProgram{
+		Program: &Program{
+			Func: func(stdin io.Reader, stdout io.Writer) int {
+				time.Sleep(100 * time.Millisecond)
+				fmt.Fprintln(stdout, "ready")
+				return 0
+			},
+		},
+	}
+
+	var term = &Terminal{
+		Transport: transport,
+	}
+
+	var story = &QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(Step{
+		Read:      "ready",
+		SkipWrite: true,
+	})
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if calls := atomic.LoadInt32(&transport.calls); calls != 0 {
+		t.Errorf("Expected state to not be queried, got %v queries instead", calls)
+	}
+}
+
+func TestStoryNoEchoStepWithRead(t *testing.T) {
+	var story = &QueueStory{}
+
+	story.Add(Step{
+		Read:   "Password:",
+		NoEcho: true,
+		Write:  "secret",
+	})
+
+	if _, err := story.HandleState(State{Mode: Mode{Echo: true}}); err != SkipWrite {
+		t.Errorf("Expected step with Read to not match on state, got %v instead", err)
+	}
+
+	if _, err := story.HandleLine("Password:"); err != SkipWrite {
+		t.Errorf("Expected step to not match while echo is enabled, got %v instead", err)
+	}
+
+	if _, err := story.HandleState(State{Mode: Mode{Echo: true}}); err != SkipWrite {
+		t.Errorf("Expected step to wait for echo to be disabled, got %v instead", err)
+	}
+
+	if in, err := story.HandleState(State{}); in != "secret" || err != nil {
+		t.Errorf("Expected step to match once echo is disabled, got (%v, %v) instead", in, err)
+	}
+}
@@ -0,0 +1,92 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package pseudoterm
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func getMode(fd uintptr) (m Mode, err error) {
+	t, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+
+	if err != nil {
+		return m, err
+	}
+
+	m.Echo = t.Lflag&unix.ECHO != 0
+	m.Canonical = t.Lflag&unix.ICANON != 0
+	m.Raw = t.Lflag&(unix.ECHO|unix.ICANON|unix.ISIG) == 0
+	return m, nil
+}
+
+// makeRaw puts the terminal into raw mode, returning a function to restore it
+func makeRaw(fd int) (restore func() error, err error) {
+	t, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var old = *t
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB
+	t.Cflag |= unix.CS8
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+
+	if err = unix.IoctlSetTermios(fd, ioctlSetTermios, t); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return unix.IoctlSetTermios(fd, ioctlSetTermios, &old)
+	}, nil
+}
+
+func getSize(fd int) (rows, cols uint16, err error) {
+	ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return ws.Row, ws.Col, nil
+}
+
+func notifyResize(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
+
+// nonblockingDup duplicates the file descriptor in non-blocking mode, so reading
+// from it can be interrupted with a deadline, returning a function to restore
+// the blocking mode of the original
+func nonblockingDup(fd int) (f *os.File, restore func(), err error) {
+	flags, err := unix.FcntlInt(uintptr(fd), unix.F_GETFL, 0)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nfd, err := unix.Dup(fd)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the file status flags are shared with the original file descriptor
+	if err = unix.SetNonblock(nfd, true); err != nil {
+		_ = unix.Close(nfd)
+		return nil, nil, err
+	}
+
+	return os.NewFile(uintptr(nfd), "stdin"), func() {
+		_ = unix.SetNonblock(fd, flags&unix.O_NONBLOCK != 0)
+	}, nil
+}
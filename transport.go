@@ -0,0 +1,182 @@
+package pseudoterm
+
+import (
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/kr/pty"
+)
+
+// Transport is the program side of a Terminal.
+// Reading from it returns the output of the program and writing to it sends input.
+type Transport interface {
+	// Start the program
+	Start() error
+
+	io.ReadWriteCloser
+
+	// Wait for the program to end and return its exit code
+	Wait() (exitCode int, err error)
+}
+
+// processStater is implemented by transports running a local process
+type processStater interface {
+	ProcessState() *os.ProcessState
+}
+
+// resizer is implemented by transports with a terminal size.
+// SetSize might be called before Start.
+type resizer interface {
+	SetSize(rows, cols uint16) error
+}
+
+// stderrTransport is implemented by transports supporting Terminal SeparateStderr
+type stderrTransport interface {
+	Stderr() io.Reader
+}
+
+// signaler is implemented by transports that can send signals to the program
+type signaler interface {
+	Signal(sig os.Signal) error
+}
+
+// ptyTransport runs a command on a pseudo tty
+type ptyTransport struct {
+	cmd            *exec.Cmd
+	rows           uint16
+	cols           uint16
+	separateStderr bool
+	f              *os.File
+	stderr         *os.File
+	ps             *os.ProcessState
+}
+
+func (p *ptyTransport) Start() (err error) {
+	if !p.separateStderr {
+		p.f, err = pty.StartWithSize(p.cmd, p.winsize())
+		return err
+	}
+
+	r, w, err := os.Pipe()
+
+	if err != nil {
+		return err
+	}
+
+	p.cmd.Stderr = w
+	p.f, err = pty.StartWithSize(p.cmd, p.winsize())
+	_ = w.Close()
+
+	if err != nil {
+		_ = r.Close()
+		return err
+	}
+
+	p.stderr = r
+	return nil
+}
+
+func (p *ptyTransport) Stderr() io.Reader {
+	return p.stderr
+}
+
+func (p *ptyTransport) winsize() *pty.Winsize {
+	if p.rows == 0 && p.cols == 0 {
+		return nil
+	}
+
+	return &pty.Winsize{
+		Rows: p.rows,
+		Cols: p.cols,
+	}
+}
+
+func (p *ptyTransport) SetSize(rows, cols uint16) error {
+	p.rows, p.cols = rows, cols
+	return pty.Setsize(p.f, p.winsize())
+}
+
+func (p *ptyTransport) Read(b []byte) (n int, err error) {
+	return p.f.Read(b)
+}
+
+func (p *ptyTransport) Write(b []byte) (n int, err error) {
+	return p.f.Write(b)
+}
+
+func (p *ptyTransport) Close() error {
+	var err = p.f.Close()
+
+	if p.stderr != nil {
+		if es := p.stderr.Close(); err == nil {
+			err = es
+		}
+	}
+
+	return err
+}
+
+func (p *ptyTransport) Wait() (exitCode int, err error) {
+	p.ps, err = p.cmd.Process.Wait()
+
+	if p.ps == nil {
+		return -1, err
+	}
+
+	return p.ps.ExitCode(), err
+}
+
+func (p *ptyTransport) Mode() (m Mode, err error) {
+	err = p.control(func(fd uintptr) error {
+		m, err = getMode(fd)
+		return err
+	})
+
+	return m, err
+}
+
+func (p *ptyTransport) InputRequested() (r bool, err error) {
+	err = p.control(func(fd uintptr) error {
+		r, err = inputRequested(fd)
+		return err
+	})
+
+	return r, err
+}
+
+func (p *ptyTransport) Foreground() (f Foreground, err error) {
+	err = p.control(func(fd uintptr) error {
+		f, err = foreground(fd)
+		return err
+	})
+
+	return f, err
+}
+
+// control calls fn with the file descriptor of the pseudo tty
+func (p *ptyTransport) control(fn func(fd uintptr) error) (err error) {
+	conn, err := p.f.SyscallConn()
+
+	if err != nil {
+		return err
+	}
+
+	var cerr = conn.Control(func(fd uintptr) {
+		err = fn(fd)
+	})
+
+	if cerr != nil {
+		return cerr
+	}
+
+	return err
+}
+
+func (p *ptyTransport) Signal(sig os.Signal) error {
+	return p.cmd.Process.Signal(sig)
+}
+
+func (p *ptyTransport) ProcessState() *os.ProcessState {
+	return p.ps
+}
@@ -0,0 +1,50 @@
+//go:build !windows
+// +build !windows
+
+package pseudoterm
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestPtyTransportCloseStderr(t *testing.T) {
+	var p = &ptyTransport{
+		cmd:            exec.Command("sh", "-c", `trap "" HUP; echo ready; exec sleep 10`),
+		separateStderr: true,
+	}
+
+	if err := p.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	defer func() {
+		_ = p.cmd.Process.Kill()
+		_, _ = p.Wait()
+	}()
+
+	// wait for SIGHUP to be ignored, so closing the pseudo tty doesn't end the program
+	if _, err := bufio.NewReader(p).ReadString('\n'); err != nil {
+		t.Fatalf("Expected no error reading, got %v instead", err)
+	}
+
+	var read = make(chan struct{})
+
+	go func() {
+		_, _ = ioutil.ReadAll(p.Stderr())
+		close(read)
+	}()
+
+	if err := p.Close(); err != nil {
+		t.Errorf("Expected no error closing, got %v instead", err)
+	}
+
+	select {
+	case <-read:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected reading stderr to stop once the transport is closed")
+	}
+}
@@ -0,0 +1,223 @@
+package pseudoterm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// Pipes is a Transport running Command with plain pipes instead of a pseudo tty,
+// for programs that behave differently or refuse to run on a tty.
+// The standard error is combined with the standard output, unless Command.Stderr is set.
+// There is no tty to echo the input or handle EOT: writing EOT closes the standard input.
+type Pipes struct {
+	Command *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  *os.File
+}
+
+// Start the program
+func (p *Pipes) Start() (err error) {
+	if p.stdout != nil {
+		return errors.New("Already started")
+	}
+
+	if p.stdin, err = p.Command.StdinPipe(); err != nil {
+		return err
+	}
+
+	r, w, err := os.Pipe()
+
+	if err != nil {
+		return err
+	}
+
+	p.Command.Stdout = w
+
+	if p.Command.Stderr == nil {
+		p.Command.Stderr = w
+	}
+
+	err = p.Command.Start()
+	_ = w.Close()
+
+	if err != nil {
+		_ = r.Close()
+		_ = p.stdin.Close()
+		return err
+	}
+
+	p.stdout = r
+	return nil
+}
+
+// Read the output of the program
+func (p *Pipes) Read(b []byte) (n int, err error) {
+	return p.stdout.Read(b)
+}
+
+// Write input to the program
+func (p *Pipes) Write(b []byte) (n int, err error) {
+	var eot = bytes.IndexByte(b, EOT[0])
+
+	if eot == -1 {
+		return p.stdin.Write(b)
+	}
+
+	if _, err = p.stdin.Write(b[:eot]); err != nil {
+		return 0, err
+	}
+
+	return len(b), p.stdin.Close()
+}
+
+// Close the input and output of the program
+func (p *Pipes) Close() error {
+	_ = p.stdin.Close()
+	return p.stdout.Close()
+}
+
+// Wait for the program to end
+func (p *Pipes) Wait() (exitCode int, err error) {
+	err = p.Command.Wait()
+
+	if _, ok := err.(*exec.ExitError); ok {
+		err = nil
+	}
+
+	if p.Command.ProcessState == nil {
+		return -1, err
+	}
+
+	return p.Command.ProcessState.ExitCode(), err
+}
+
+// Signal sends a signal to the program
+func (p *Pipes) Signal(sig os.Signal) error {
+	return p.Command.Process.Signal(sig)
+}
+
+// ProcessState of the program, once it ends
+func (p *Pipes) ProcessState() *os.ProcessState {
+	return p.Command.ProcessState
+}
+
+// Stream is a Transport for a program on the other side of an io.ReadWriteCloser,
+// such as a serial console. The program ends when reading from it fails,
+// with exit code 0 on io.EOF or when the stream is closed, and -1 otherwise.
+// Input is written as is, and the program on the other side is responsible for handling EOT.
+type Stream struct {
+	Conn     io.ReadWriteCloser
+	done     chan empty
+	once     sync.Once
+	mu       sync.Mutex
+	closed   bool
+	exitCode int
+	err      error
+}
+
+// Start the stream
+func (s *Stream) Start() error {
+	if s.Conn == nil {
+		return errors.New("Missing stream connection")
+	}
+
+	if s.done != nil {
+		return errors.New("Already started")
+	}
+
+	s.done = make(chan empty)
+	return nil
+}
+
+// Read the output of the program
+func (s *Stream) Read(b []byte) (n int, err error) {
+	n, err = s.Conn.Read(b)
+
+	if err != nil {
+		s.end(err)
+	}
+
+	return n, err
+}
+
+// Write input to the program
+func (s *Stream) Write(b []byte) (n int, err error) {
+	return s.Conn.Write(b)
+}
+
+// Close the stream
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	var err = s.Conn.Close()
+	s.end(io.EOF)
+	return err
+}
+
+// Wait for the program to end
+func (s *Stream) Wait() (exitCode int, err error) {
+	<-s.done
+	return s.exitCode, s.err
+}
+
+func (s *Stream) end(err error) {
+	s.once.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if err != io.EOF && !s.closed {
+			s.exitCode, s.err = -1, err
+		}
+
+		close(s.done)
+	})
+}
+
+// Net is a Transport for a program on the other side of a network connection,
+// such as a network REPL. It dials Network and Address on Start, unless Conn is set.
+// It works like a Stream otherwise.
+type Net struct {
+	Network string
+	Address string
+	Conn    net.Conn
+	stream  Stream
+}
+
+// Start dialing the address, if there is no connection yet
+func (n *Net) Start() (err error) {
+	if n.Conn == nil {
+		if n.Conn, err = net.Dial(n.Network, n.Address); err != nil {
+			return err
+		}
+	}
+
+	n.stream.Conn = n.Conn
+	return n.stream.Start()
+}
+
+// Read the output of the program
+func (n *Net) Read(b []byte) (int, error) {
+	return n.stream.Read(b)
+}
+
+// Write input to the program
+func (n *Net) Write(b []byte) (int, error) {
+	return n.stream.Write(b)
+}
+
+// Close the connection
+func (n *Net) Close() error {
+	return n.stream.Close()
+}
+
+// Wait for the program to end
+func (n *Net) Wait() (exitCode int, err error) {
+	return n.stream.Wait()
+}
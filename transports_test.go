@@ -0,0 +1,201 @@
+//go:build !windows
+// +build !windows
+
+package pseudoterm_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/henvic/pseudoterm"
+	"github.com/henvic/pseudoterm/pseudotermtest"
+)
+
+func nameStory() *pseudoterm.QueueStory {
+	var story = &pseudoterm.QueueStory{
+		Timeout: 5 * time.Second,
+	}
+
+	story.Add(pseudoterm.Step{
+		Read:  "Your name:",
+		Write: "Henrique",
+	},
+		pseudoterm.Step{
+			Read:      "Hi, Henrique",
+			SkipWrite: true,
+		})
+
+	return story
+}
+
+// serveName plays a program asking for a name on the server side of a connection
+func serveName(conn io.ReadWriteCloser) {
+	defer conn.Close()
+	fmt.Fprint(conn, "Your name: ")
+	var name, _ = bufio.NewReader(conn).ReadString('\n')
+	fmt.Fprintf(conn, "Hi, %s", name)
+}
+
+func TestPipesWithStory(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var term = &pseudoterm.Terminal{
+		Transport: &pseudoterm.Pipes{
+			Command: exec.Command("bash", "-c",
+				`[ -t 0 ] && exit 1; printf "Your name: "; read n; echo "Hi, $n"; echo oops >&2; exit 3`),
+		},
+		EchoStream: echoStream,
+	}
+
+	var story = nameStory()
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success.")
+	}
+
+	if ps := term.Wait(); ps == nil || ps.ExitCode() != 3 {
+		t.Errorf("Expected process state with exit code 3, got %v instead", ps)
+	}
+
+	if code := term.ExitCode(); code != 3 {
+		t.Errorf("Expected exit code 3, got %v instead", code)
+	}
+
+	<-term.OutputDone()
+
+	// input is not echoed without a tty
+	pseudotermtest.AssertSimilar(t, "Your name: Hi, Henrique\noops", echoStream.String())
+}
+
+func TestPipesStopClosesInput(t *testing.T) {
+	var echoStream = &bytes.Buffer{}
+	var term = &pseudoterm.Terminal{
+		Transport: &pseudoterm.Pipes{
+			Command: exec.Command("cat"),
+		},
+		EchoStream: echoStream,
+	}
+
+	if err := term.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if _, err := term.WriteLine("hello"); err != nil {
+		t.Errorf("Expected no error, got %v instead", err)
+	}
+
+	if _, err := term.Write(pseudoterm.EOT); err != nil {
+		t.Errorf("Expected no error, got %v instead", err)
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	if code := term.ExitCode(); code != 0 {
+		t.Errorf("Expected exit code 0, got %v instead", code)
+	}
+
+	if echoStream.String() != "hello\n" {
+		t.Errorf("Expected output to be %q, got %q instead", "hello\n", echoStream.String())
+	}
+}
+
+func TestPipesStartError(t *testing.T) {
+	var term = &pseudoterm.Terminal{
+		Transport: &pseudoterm.Pipes{
+			Command: exec.Command("mocks/not-found"),
+		},
+	}
+
+	if err := term.Start(); err == nil {
+		t.Errorf("Expected error starting program that doesn't exist")
+	}
+}
+
+func TestStreamWithStory(t *testing.T) {
+	var client, server = net.Pipe()
+	go serveName(server)
+
+	var term = &pseudoterm.Terminal{
+		Transport: &pseudoterm.Stream{
+			Conn: client,
+		},
+	}
+
+	var story = nameStory()
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success.")
+	}
+
+	if ps := term.Wait(); ps != nil {
+		t.Errorf("Expected no process state, got %v instead", ps)
+	}
+
+	if code := term.ExitCode(); code != 0 {
+		t.Errorf("Expected exit code 0, got %v instead", code)
+	}
+}
+
+func TestStreamMissingConn(t *testing.T) {
+	var term = &pseudoterm.Terminal{
+		Transport: &pseudoterm.Stream{},
+	}
+
+	if err := term.Start(); err == nil || err.Error() != "Missing stream connection" {
+		t.Errorf(`Unexpected error %v, wanted "Missing stream connection" instead`, err)
+	}
+}
+
+func TestNetWithStory(t *testing.T) {
+	var l, err = net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Expected no error listening, got %v instead", err)
+	}
+
+	defer l.Close()
+
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			serveName(conn)
+		}
+	}()
+
+	var echoStream = &bytes.Buffer{}
+	var term = &pseudoterm.Terminal{
+		Transport: &pseudoterm.Net{
+			Network: "tcp",
+			Address: l.Addr().String(),
+		},
+		EchoStream: echoStream,
+	}
+
+	var story = nameStory()
+
+	if err := term.Run(story); err != nil {
+		t.Errorf("Expected no error during run, got %v instead", err)
+	}
+
+	if !story.Success() {
+		t.Errorf("Story didn't success.")
+	}
+
+	term.Wait()
+	<-term.OutputDone()
+
+	pseudotermtest.AssertSimilar(t, "Your name: Hi, Henrique", echoStream.String())
+}